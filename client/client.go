@@ -0,0 +1,130 @@
+//Package client is typed Go client of the recipes REST API. It speaks /v2/recipes, apart from rating which has no v2
+//endpoint yet. It depends on standard library and pkg/errors only, so services using it don't pull the server in.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultRetries = 3
+	defaultBackoff = 100 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+}
+
+type Option func(*Client)
+
+//WithHTTPClient replaces http.DefaultClient, e.g. to set timeouts or transport
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+//WithRetries sets how many times a failed call is repeated and how long to wait before the first repeat,
+//every next wait is twice as long. Zero retries turns retrying off.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+//New takes URL the service listens on, e.g. http://svc-recipes:8080
+func New(baseURL string, options ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		retries:    defaultRetries,
+		backoff:    defaultBackoff,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+//do sends JSON body and decodes JSON response into out, both can be nil. Problem responses become *Error.
+//GET and PUT are repeated on 5xx and connection errors. POST isn't idempotent, so it's repeated only on 503 which
+//the service returns before doing anything.
+func (c *Client) do(ctx context.Context, method, path string, in interface{}, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return errors.Wrap(err, "failed to marshal request")
+		}
+	}
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := c.send(ctx, method, path, body, out)
+		if err == nil || !retry || attempt >= c.retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "%s %s cancelled while retrying: %v", method, path, err)
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+//send makes single attempt and tells whether it's worth repeating
+func (c *Client) send(ctx context.Context, method, path string, body []byte, out interface{}) (bool, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to create %s %s request", method, path)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		//the context error is more telling than the transport one
+		if ctx.Err() != nil {
+			return false, errors.Wrapf(ctx.Err(), "%s %s", method, path)
+		}
+		return method != http.MethodPost, errors.Wrapf(err, "%s %s failed", method, path)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		retry := res.StatusCode == http.StatusServiceUnavailable ||
+			(res.StatusCode >= http.StatusInternalServerError && method != http.MethodPost)
+		return retry, newError(res)
+	}
+	if out == nil {
+		_, err = io.Copy(ioutil.Discard, res.Body)
+		return false, errors.Wrapf(err, "failed to read %s %s response", method, path)
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return false, errors.Wrapf(err, "failed to decode %s %s response", method, path)
+	}
+	return false, nil
+}
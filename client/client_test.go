@@ -0,0 +1,137 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gobonoid/svc-recipes/client"
+	"github.com/gobonoid/svc-recipes/health"
+	"github.com/gobonoid/svc-recipes/importer"
+	"github.com/gobonoid/svc-recipes/interface/rest/handler"
+	"github.com/gobonoid/svc-recipes/interface/rest/server"
+	"github.com/gobonoid/svc-recipes/metrics"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/gobonoid/svc-recipes/webhooks"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//newTestServer runs the real handlers, failures makes the first requests fail with given statuses
+func newTestServer(t *testing.T, failures ...int) (*httptest.Server, *int32, func()) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Chilli", GoustoReference: 59}))
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 2, Title: "Fish Pie"}))
+	recipesImporter := importer.NewImporter(recipesModel, 1)
+	dispatcher := webhooks.NewDispatcher(recipesModel, logrus.New())
+	graphQLHandler, err := handler.NewGraphQLHandler(recipesModel)
+	require.NoError(t, err)
+	recipesServer := server.NewRecipesServer(0, logrus.New(), handler.NewRecipesHandler(recipesModel), handler.NewImportsHandler(recipesImporter), graphQLHandler, handler.NewWebhooksHandler(dispatcher), health.NewRegistry(), metrics.New())
+
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1))
+		if n <= len(failures) {
+			w.WriteHeader(failures[n-1])
+			return
+		}
+		recipesServer.ServeHTTP(w, r)
+	}))
+	return ts, &requests, func() {
+		ts.Close()
+		recipesImporter.Stop()
+		dispatcher.Stop()
+	}
+}
+
+func TestClient_Recipes(t *testing.T) {
+	ts, _, stop := newTestServer(t)
+	defer stop()
+	c := client.New(ts.URL)
+	ctx := context.Background()
+
+	recipes, err := c.ListRecipes(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(recipes))
+	assert.Equal(t, "Pork Chilli", recipes[0].Title)
+
+	recipes, err = c.ListRecipes(ctx, &client.ListOptions{Limit: 1, Page: 2})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(recipes))
+	assert.Equal(t, 2, recipes[0].ID)
+
+	created, err := c.CreateRecipe(ctx, &client.Recipe{Title: "Lamb Curry", Ingredients: []string{"lamb", "rice"}})
+	require.NoError(t, err)
+	assert.Equal(t, "lamb-curry", created.Slug)
+	assert.NotZero(t, created.ID)
+
+	created.Nutrition.FatGrams = 12
+	updated, err := c.UpdateRecipe(ctx, created.ID, created)
+	require.NoError(t, err)
+	assert.Equal(t, 12, updated.Nutrition.FatGrams)
+
+	require.NoError(t, c.RateRecipe(ctx, created.ID, client.Rate{Rate: 4}))
+	recipe, err := c.GetRecipe(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, float32(4), recipe.AverageRate)
+	assert.Equal(t, []string{"lamb", "rice"}, recipe.Ingredients)
+	assert.Nil(t, recipe.CreatedAt)
+}
+
+func TestClient_Errors(t *testing.T) {
+	ts, _, stop := newTestServer(t)
+	defer stop()
+	c := client.New(ts.URL)
+	ctx := context.Background()
+
+	_, err := c.GetRecipe(ctx, 42)
+	assert.Equal(t, client.NotFoundError, errors.Cause(err))
+
+	_, err = c.UpdateRecipe(ctx, 42, &client.Recipe{Title: "Lamb Curry"})
+	assert.Equal(t, client.NotFoundError, errors.Cause(err))
+
+	_, err = c.UpdateRecipe(ctx, 1, &client.Recipe{Nutrition: client.Nutrition{FatGrams: -1}})
+	assert.Equal(t, client.InvalidRecipeError, errors.Cause(err))
+	e := err.(*client.Error)
+	assert.Equal(t, http.StatusUnprocessableEntity, e.StatusCode)
+	require.Equal(t, 2, len(e.Violations))
+	assert.Equal(t, "nutrition.fat_grams", e.Violations[1].Field)
+
+	_, err = c.ListRecipes(ctx, &client.ListOptions{Limit: -1})
+	assert.Equal(t, client.RequestError, errors.Cause(err))
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+}
+
+func TestClient_Retries(t *testing.T) {
+	ts, requests, stop := newTestServer(t, http.StatusBadGateway, http.StatusServiceUnavailable)
+	defer stop()
+	c := client.New(ts.URL, client.WithRetries(2, time.Millisecond))
+	recipe, err := c.GetRecipe(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Pork Chilli", recipe.Title)
+	assert.Equal(t, int32(3), atomic.LoadInt32(requests))
+
+	//POST is repeated on 503 only, 502 may mean the recipe was created
+	ts, requests, stop = newTestServer(t, http.StatusBadGateway)
+	defer stop()
+	c = client.New(ts.URL, client.WithRetries(2, time.Millisecond))
+	_, err = c.CreateRecipe(context.Background(), &client.Recipe{Title: "Lamb Curry"})
+	assert.Equal(t, http.StatusBadGateway, err.(*client.Error).StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+}
+
+func TestClient_Context(t *testing.T) {
+	ts, requests, stop := newTestServer(t, http.StatusInternalServerError, http.StatusInternalServerError)
+	defer stop()
+	c := client.New(ts.URL, client.WithRetries(5, time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.GetRecipe(ctx, 1)
+	assert.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+}
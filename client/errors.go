@@ -0,0 +1,81 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//Errors mirror the model ones, compare errors.Cause of returned error with them.
+//RequestError is the cause of every other error response.
+var (
+	NotFoundError                = errors.New("Not found")
+	DuplicateError               = errors.New("Duplicate entry")
+	GoustoReferenceConflictError = errors.New("Gousto reference already used")
+	InvalidRecipeError           = errors.New("Invalid recipe")
+	RequestError                 = errors.New("Request failed")
+)
+
+//problemErrors maps problem types of the service to errors above
+var problemErrors = map[string]error{
+	"/problems/not-found":                 NotFoundError,
+	"/problems/duplicate":                 DuplicateError,
+	"/problems/gousto-reference-conflict": GoustoReferenceConflictError,
+	"/problems/validation":                InvalidRecipeError,
+}
+
+//maxErrorBody keeps error of a misbehaving proxy short
+const maxErrorBody = 4096
+
+//Violation is a validation rule recipe breaks, Field is named the /v2 way
+type Violation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+//Error is any response with 4xx or 5xx status. Problem fields are empty when the body isn't application/problem+json.
+type Error struct {
+	StatusCode int         `json:"-"`
+	Type       string      `json:"type"`
+	Title      string      `json:"title"`
+	Detail     string      `json:"detail"`
+	RequestID  string      `json:"request_id"`
+	Violations []Violation `json:"violations"`
+}
+
+func newError(res *http.Response) *Error {
+	e := &Error{StatusCode: res.StatusCode}
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+	if err != nil || json.Unmarshal(body, e) != nil {
+		e.Title = http.StatusText(res.StatusCode)
+		e.Detail = strings.TrimSpace(string(body))
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	message := fmt.Sprintf("%d %s", e.StatusCode, e.Title)
+	if e.Detail != "" {
+		message += ": " + e.Detail
+	}
+	return message
+}
+
+//Cause is what errors.Cause looks for, it's always one of the package errors
+func (e *Error) Cause() error {
+	if err, ok := problemErrors[e.Type]; ok {
+		return err
+	}
+	return RequestError
+}
+
+//Unwrap does the same for errors.Is
+func (e *Error) Unwrap() error {
+	return e.Cause()
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	recipesPath   = "/v2/recipes"
+	recipesV1Path = "/recipes"
+)
+
+//Recipe is the /v2 representation, dates are nil when not set
+type Recipe struct {
+	ID                     int        `json:"id"`
+	CreatedAt              *time.Time `json:"created_at"`
+	UpdatedAt              *time.Time `json:"updated_at"`
+	BoxType                string     `json:"box_type"`
+	Title                  string     `json:"title"`
+	Slug                   string     `json:"slug"`
+	ShortTitle             string     `json:"short_title"`
+	MarketingDescription   string     `json:"marketing_description"`
+	Nutrition              Nutrition  `json:"nutrition"`
+	Bulletpoints           []string   `json:"bulletpoints"`
+	DietType               string     `json:"diet_type"`
+	Season                 string     `json:"season"`
+	Base                   string     `json:"base"`
+	ProteinSource          string     `json:"protein_source"`
+	PreparationTimeMinutes int        `json:"preparation_time_minutes"`
+	ShelfLifeDays          int        `json:"shelf_life_days"`
+	EquipmentNeeded        string     `json:"equipment_needed"`
+	OriginCountry          string     `json:"origin_country"`
+	Cuisine                string     `json:"cuisine"`
+	Ingredients            []string   `json:"ingredients"`
+	GoustoReference        int        `json:"gousto_reference"`
+	AverageRate            float32    `json:"average_rate"`
+}
+
+type Nutrition struct {
+	CaloriesKCal int `json:"calories_kcal"`
+	ProteinGrams int `json:"protein_grams"`
+	FatGrams     int `json:"fat_grams"`
+	CarbsGrams   int `json:"carbs_grams"`
+}
+
+//Rate field names are the ones /recipes/{id}/rates takes
+type Rate struct {
+	Rate    int        `json:"Rate"`
+	RatedAt *time.Time `json:"RatedAt"`
+	RatedBy string     `json:"RatedBy"`
+}
+
+//ListOptions zero values are left out, so the service defaults apply
+type ListOptions struct {
+	//Limit is page size, all recipes are returned when it's 0
+	Limit int
+	//Page starts from 1
+	Page int
+	//GoustoReference filters recipes by it, Limit and Page are ignored then
+	GoustoReference int
+}
+
+func (o *ListOptions) query() string {
+	if o == nil {
+		return ""
+	}
+	values := url.Values{}
+	if o.Limit != 0 {
+		values.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Page != 0 {
+		values.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.GoustoReference != 0 {
+		values.Set("gousto_reference", strconv.Itoa(o.GoustoReference))
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return "?" + values.Encode()
+}
+
+//ListRecipes returns recipes ordered by id, options can be nil
+func (c *Client) ListRecipes(ctx context.Context, options *ListOptions) ([]*Recipe, error) {
+	var recipes []*Recipe
+	if err := c.do(ctx, http.MethodGet, recipesPath+options.query(), nil, &recipes); err != nil {
+		return nil, err
+	}
+	return recipes, nil
+}
+
+func (c *Client) GetRecipe(ctx context.Context, id int) (*Recipe, error) {
+	recipe := &Recipe{}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("%s/%d", recipesPath, id), nil, recipe); err != nil {
+		return nil, err
+	}
+	return recipe, nil
+}
+
+//CreateRecipe returns the recipe as the service stored it, with id and slug it assigned
+func (c *Client) CreateRecipe(ctx context.Context, recipe *Recipe) (*Recipe, error) {
+	created := &Recipe{}
+	if err := c.do(ctx, http.MethodPost, recipesPath, recipe, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+//UpdateRecipe replaces whole recipe, ID of the given one is ignored
+func (c *Client) UpdateRecipe(ctx context.Context, id int, recipe *Recipe) (*Recipe, error) {
+	updated := &Recipe{}
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("%s/%d", recipesPath, id), recipe, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+//RateRecipe uses v1 endpoint, there's no v2 one yet
+func (c *Client) RateRecipe(ctx context.Context, id int, rate Rate) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("%s/%d/rates", recipesV1Path, id), rate, nil)
+}
@@ -0,0 +1,291 @@
+//Package config layers settings of the service: defaults, then config file, then environment, then flags.
+//Config file is YAML, JSON works too as it's YAML as well.
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gobonoid/svc-recipes/tracing"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+//EnvPrefix starts every environment variable, e.g. RECIPES_HTTP_PORT for http-port flag
+const EnvPrefix = "RECIPES_"
+
+type Config struct {
+	HTTPPort   int    `yaml:"http_port"`
+	GRPCPort   int    `yaml:"grpc_port"`
+	DataSource string `yaml:"data_source"`
+	//ImportsQueueSize is how many uploads can wait for the importer
+	ImportsQueueSize int `yaml:"imports_queue_size"`
+	//MaxUploadSize is in bytes, uploads are held in memory until imported
+	MaxUploadSize int `yaml:"max_upload_size"`
+	//EventsCapacity is how many recent events /recipes/events can resume from
+	EventsCapacity int      `yaml:"events_capacity"`
+	Log            Log      `yaml:"log"`
+	Timeouts       Timeouts `yaml:"timeouts"`
+	Features       Features `yaml:"features"`
+	Tracing        Tracing  `yaml:"tracing"`
+
+	//Print is --print-config, the service shows resulting config and exits
+	Print bool `yaml:"-"`
+}
+
+type Log struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+type Timeouts struct {
+	//Drain is how long /readyz fails before servers stop taking requests, so load balancers notice
+	Drain time.Duration `yaml:"drain"`
+	//Shutdown is how long servers drain running calls
+	Shutdown time.Duration `yaml:"shutdown"`
+	//Webhook is single delivery attempt
+	Webhook time.Duration `yaml:"webhook"`
+}
+
+type Tracing struct {
+	//Exporter is one of none, otlp, stdout and file, see tracing.Setup
+	Exporter string `yaml:"exporter"`
+	File     string `yaml:"file"`
+	//Endpoint is host:port of OTLP/HTTP collector
+	Endpoint string `yaml:"endpoint"`
+	Insecure bool   `yaml:"insecure"`
+}
+
+type Features struct {
+	GRPC                  bool `yaml:"grpc"`
+	UniqueGoustoReference bool `yaml:"unique_gousto_reference"`
+}
+
+func Default() *Config {
+	return &Config{
+		HTTPPort:         8080,
+		GRPCPort:         8081,
+		DataSource:       "recipe-data.csv",
+		ImportsQueueSize: 10,
+		MaxUploadSize:    32 << 20,
+		EventsCapacity:   1000,
+		Log:              Log{Level: "info", Format: "json"},
+		Timeouts:         Timeouts{Shutdown: 10 * time.Second, Webhook: 10 * time.Second},
+		Features:         Features{GRPC: true},
+		Tracing:          Tracing{Exporter: tracing.ExporterNone, Endpoint: "localhost:4318"},
+	}
+}
+
+//setting is single flag, its environment variable comes from the name
+type setting struct {
+	name  string
+	usage string
+	field func(c *Config) interface{}
+}
+
+func (s setting) env() string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(s.name, "-", "_", -1))
+}
+
+var settings = []setting{
+	{"http-port", "REST API port", func(c *Config) interface{} { return &c.HTTPPort }},
+	{"grpc-port", "gRPC API port", func(c *Config) interface{} { return &c.GRPCPort }},
+	{"data-source", "CSV with recipes loaded on start", func(c *Config) interface{} { return &c.DataSource }},
+	{"imports-queue-size", "How many uploads can wait for the importer", func(c *Config) interface{} { return &c.ImportsQueueSize }},
+	{"max-upload-size", "Largest upload /imports takes, in bytes", func(c *Config) interface{} { return &c.MaxUploadSize }},
+	{"events-capacity", "How many recent events /recipes/events can resume from", func(c *Config) interface{} { return &c.EventsCapacity }},
+	{"log-level", "One of debug, info, warning, error", func(c *Config) interface{} { return &c.Log.Level }},
+	{"log-format", "json or text", func(c *Config) interface{} { return &c.Log.Format }},
+	{"drain-timeout", "How long /readyz fails before servers stop taking requests", func(c *Config) interface{} { return &c.Timeouts.Drain }},
+	{"shutdown-timeout", "How long servers drain running calls", func(c *Config) interface{} { return &c.Timeouts.Shutdown }},
+	{"webhook-timeout", "Timeout of single webhook delivery", func(c *Config) interface{} { return &c.Timeouts.Webhook }},
+	{"grpc", "Serve gRPC API", func(c *Config) interface{} { return &c.Features.GRPC }},
+	{"unique-gousto-reference", "Refuse recipes with gousto reference already used", func(c *Config) interface{} { return &c.Features.UniqueGoustoReference }},
+	{"trace-exporter", "Where spans go: none, otlp, stdout or file", func(c *Config) interface{} { return &c.Tracing.Exporter }},
+	{"trace-file", "File spans are appended to with file exporter", func(c *Config) interface{} { return &c.Tracing.File }},
+	{"trace-endpoint", "host:port of OTLP/HTTP collector", func(c *Config) interface{} { return &c.Tracing.Endpoint }},
+	{"trace-insecure", "Send spans to OTLP collector over plain HTTP", func(c *Config) interface{} { return &c.Tracing.Insecure }},
+}
+
+//set parses value into the field setting points to
+func (s setting) set(c *Config, value string) error {
+	var err error
+	switch field := s.field(c).(type) {
+	case *int:
+		*field, err = strconv.Atoi(value)
+	case *string:
+		*field = value
+	case *bool:
+		*field, err = strconv.ParseBool(value)
+	case *time.Duration:
+		*field, err = time.ParseDuration(value)
+	default:
+		err = errors.Errorf("unsupported type %T", field)
+	}
+	return errors.Wrapf(err, "incorrect %s", s.name)
+}
+
+func (s setting) value(c *Config) string {
+	return fmt.Sprint(reflect.ValueOf(s.field(c)).Elem().Interface())
+}
+
+//Load builds config from command line arguments (without program name) and environment, lookupEnv is os.LookupEnv
+//outside tests. Config file is taken from --config flag or RECIPES_CONFIG.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	defaults := Default()
+	fs := flag.NewFlagSet("svc-recipes", flag.ContinueOnError)
+	path := fs.String("config", "", "YAML or JSON config file, "+EnvPrefix+"CONFIG works too")
+	printConfig := fs.Bool("print-config", false, "Print resulting config and exit")
+	for _, s := range settings {
+		if _, ok := s.field(defaults).(*bool); ok {
+			fs.Bool(s.name, *s.field(defaults).(*bool), s.usage+", "+s.env())
+			continue
+		}
+		fs.String(s.name, s.value(defaults), s.usage+", "+s.env())
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, errors.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	c := defaults
+	if *path == "" {
+		*path, _ = lookupEnv(EnvPrefix + "CONFIG")
+	}
+	if *path != "" {
+		if err := c.load(*path); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range settings {
+		if value, ok := lookupEnv(s.env()); ok {
+			if err := s.set(c, value); err != nil {
+				return nil, errors.Wrap(err, s.env())
+			}
+		}
+	}
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.name == f.Name && err == nil {
+				err = s.set(c, f.Value.String())
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.Print = *printConfig
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+//load overrides only settings the file has
+func (c *Config) load(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "can't read config file")
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	//empty file is fine, it just doesn't change anything
+	if err := decoder.Decode(c); err != nil && err != io.EOF {
+		return errors.Wrapf(err, "can't parse config file %s", path)
+	}
+	return nil
+}
+
+//Validate reports every problem at once
+func (c *Config) Validate() error {
+	var problems []string
+	for _, port := range []struct {
+		name  string
+		value int
+	}{{"http_port", c.HTTPPort}, {"grpc_port", c.GRPCPort}} {
+		if port.value < 1 || port.value > 65535 {
+			problems = append(problems, port.name+" has to be between 1 and 65535")
+		}
+	}
+	if c.Features.GRPC && c.HTTPPort == c.GRPCPort {
+		problems = append(problems, "http_port and grpc_port have to differ")
+	}
+	if c.DataSource == "" {
+		problems = append(problems, "data_source is required")
+	}
+	if c.ImportsQueueSize < 1 {
+		problems = append(problems, "imports_queue_size has to be positive")
+	}
+	if c.MaxUploadSize < 1 {
+		problems = append(problems, "max_upload_size has to be positive")
+	}
+	if c.EventsCapacity < 1 {
+		problems = append(problems, "events_capacity has to be positive")
+	}
+	if _, err := logrus.ParseLevel(c.Log.Level); err != nil {
+		problems = append(problems, "log.level has to be one of debug, info, warning, error")
+	}
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		problems = append(problems, "log.format has to be json or text")
+	}
+	if c.Timeouts.Drain < 0 {
+		problems = append(problems, "timeouts.drain can't be negative")
+	}
+	if c.Timeouts.Shutdown <= 0 {
+		problems = append(problems, "timeouts.shutdown has to be positive")
+	}
+	if c.Timeouts.Webhook <= 0 {
+		problems = append(problems, "timeouts.webhook has to be positive")
+	}
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+	case tracing.ExporterOTLP:
+		if c.Tracing.Endpoint == "" {
+			problems = append(problems, "tracing.endpoint is required with otlp exporter")
+		}
+	case tracing.ExporterFile:
+		if c.Tracing.File == "" {
+			problems = append(problems, "tracing.file is required with file exporter")
+		}
+	default:
+		problems = append(problems, "tracing.exporter has to be one of none, otlp, stdout, file")
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+//YAML is what --print-config shows, the same format config file takes
+func (c *Config) YAML() ([]byte, error) {
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), encoder.Close()
+}
+
+//Logger is configured by Log
+func (c *Config) Logger() *logrus.Logger {
+	logger := logrus.New()
+	logger.Level, _ = logrus.ParseLevel(c.Log.Level)
+	if c.Log.Format == "json" {
+		logger.Formatter = &logrus.JSONFormatter{}
+	} else {
+		logger.Formatter = &logrus.TextFormatter{}
+	}
+	return logger
+}
@@ -0,0 +1,111 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gobonoid/svc-recipes/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "config")
+	require.NoError(t, err)
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	c, err := config.Load(nil, env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, config.Default(), c)
+}
+
+func TestLoad_Layers(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+http_port: 9000
+grpc_port: 9001
+log:
+  level: debug
+timeouts:
+  shutdown: 30s
+features:
+  unique_gousto_reference: true
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	c, err := config.Load(
+		[]string{"--config", path, "--grpc-port", "9101", "--grpc=false", "--print-config"},
+		env(map[string]string{"RECIPES_GRPC_PORT": "9201", "RECIPES_LOG_FORMAT": "text", "RECIPES_DATA_SOURCE": "/data/recipes.csv"}),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 9000, c.HTTPPort, "file")
+	assert.Equal(t, 9101, c.GRPCPort, "flag beats environment")
+	assert.Equal(t, "text", c.Log.Format, "environment")
+	assert.Equal(t, "debug", c.Log.Level, "file, rest of log section keeps defaults")
+	assert.Equal(t, "/data/recipes.csv", c.DataSource)
+	assert.Equal(t, 30*time.Second, c.Timeouts.Shutdown)
+	assert.Equal(t, 10*time.Second, c.Timeouts.Webhook)
+	assert.True(t, c.Features.UniqueGoustoReference)
+	assert.False(t, c.Features.GRPC)
+	assert.True(t, c.Print)
+
+	printed, err := c.YAML()
+	require.NoError(t, err)
+	assert.Contains(t, string(printed), "shutdown: 30s")
+}
+
+func TestLoad_JSONFile(t *testing.T) {
+	path := writeFile(t, "config.json", `{"http_port": 9000, "features": {"grpc": false}}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	c, err := config.Load(nil, env(map[string]string{"RECIPES_CONFIG": path}))
+	require.NoError(t, err)
+	assert.Equal(t, 9000, c.HTTPPort)
+	assert.False(t, c.Features.GRPC)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	path := writeFile(t, "config.yaml", "http_prot: 9000\n")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	for _, tc := range []struct {
+		args    []string
+		env     map[string]string
+		message string
+	}{
+		{[]string{"--config", path}, nil, "field http_prot not found"},
+		{[]string{"--config", "/nope.yaml"}, nil, "can't read config file"},
+		{nil, map[string]string{"RECIPES_HTTP_PORT": "eighty"}, "RECIPES_HTTP_PORT: incorrect http-port"},
+		{[]string{"--shutdown-timeout", "10"}, nil, "incorrect shutdown-timeout"},
+		{[]string{"--grpc-port", "8080", "--log-level", "loud", "--data-source", ""}, nil,
+			"invalid config: http_port and grpc_port have to differ; data_source is required; log.level has to be one of debug, info, warning, error"},
+		{[]string{"--http-port", "0"}, nil, "invalid config: http_port has to be between 1 and 65535"},
+		{[]string{"serve"}, nil, "unexpected arguments: [serve]"},
+		{[]string{"--trace-exporter", "jaeger"}, nil, "invalid config: tracing.exporter has to be one of none, otlp, stdout, file"},
+		{[]string{"--trace-exporter", "otlp", "--trace-endpoint", ""}, nil, "invalid config: tracing.endpoint is required with otlp exporter"},
+		{nil, map[string]string{"RECIPES_TRACE_EXPORTER": "file"}, "invalid config: tracing.file is required with file exporter"},
+	} {
+		_, err := config.Load(tc.args, env(tc.env))
+		if assert.Error(t, err, "%v", tc.args) {
+			assert.Contains(t, err.Error(), tc.message)
+		}
+	}
+
+	c := config.Default()
+	c.Features.GRPC = false
+	c.GRPCPort = c.HTTPPort
+	assert.NoError(t, c.Validate(), "ports can clash when gRPC is off")
+}
@@ -0,0 +1,122 @@
+//Package health collects named checks subsystems plug in and tells whether the service is ready for traffic
+package health
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+const (
+	StatusOK       = "ok"
+	StatusFailing  = "failing"
+	StatusDraining = "draining"
+
+	//checkTimeout keeps a stuck dependency from stalling readiness probes
+	checkTimeout = 5 * time.Second
+)
+
+//Check returns nil when the subsystem can serve, it should respect ctx deadline
+type Check func(ctx context.Context) error
+
+type Result struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+//Report is failing when any check fails, draining overrides both
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+func (r Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+type Registry struct {
+	mx       sync.Mutex
+	names    []string
+	checks   map[string]Check
+	draining bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{checks: make(map[string]Check)}
+}
+
+//Register replaces check registered under the same name, report keeps order of first registration
+func (r *Registry) Register(name string, check Check) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if _, ok := r.checks[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.checks[name] = check
+}
+
+//Drain makes the service not ready for good, so traffic moves elsewhere while running requests finish
+func (r *Registry) Drain() {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.draining = true
+}
+
+//Ready runs all checks at once
+func (r *Registry) Ready(ctx context.Context) Report {
+	r.mx.Lock()
+	names := append([]string{}, r.names...)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = r.checks[name]
+	}
+	draining := r.draining
+	r.mx.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	report := Report{Status: StatusOK, Checks: make([]Result, len(names))}
+	var wg sync.WaitGroup
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			report.Checks[i] = run(ctx, names[i], checks[i])
+		}(i)
+	}
+	wg.Wait()
+	for _, result := range report.Checks {
+		if result.Status != StatusOK {
+			report.Status = StatusFailing
+		}
+	}
+	if draining {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+//run gives up on check which ignores ctx, it's left to finish in the background
+func run(ctx context.Context, name string, check Check) Result {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errors.Wrap(ctx.Err(), "check didn't finish")
+	}
+	result := Result{Name: name, Status: StatusOK, DurationMs: float64(time.Since(start)) / float64(time.Millisecond)}
+	if err != nil {
+		result.Status = StatusFailing
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,58 @@
+package health_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gobonoid/svc-recipes/health"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func TestRegistry_Ready(t *testing.T) {
+	r := health.NewRegistry()
+	report := r.Ready(context.Background())
+	assert.True(t, report.Ready(), "nothing to check")
+	assert.Empty(t, report.Checks)
+
+	r.Register("recipes", func(ctx context.Context) error { return errors.New("not loaded yet") })
+	r.Register("importer", func(ctx context.Context) error { return nil })
+	report = r.Ready(context.Background())
+	assert.Equal(t, health.StatusFailing, report.Status)
+	require.Equal(t, 2, len(report.Checks))
+	assert.Equal(t, health.Result{Name: "recipes", Status: health.StatusFailing, Error: "not loaded yet"}, withoutDuration(report.Checks[0]))
+	assert.Equal(t, health.Result{Name: "importer", Status: health.StatusOK}, withoutDuration(report.Checks[1]))
+
+	//replaced check keeps its place
+	r.Register("recipes", func(ctx context.Context) error { return nil })
+	report = r.Ready(context.Background())
+	assert.True(t, report.Ready())
+	assert.Equal(t, "recipes", report.Checks[0].Name)
+
+	r.Drain()
+	report = r.Ready(context.Background())
+	assert.Equal(t, health.StatusDraining, report.Status)
+	assert.Equal(t, health.StatusOK, report.Checks[0].Status)
+}
+
+func TestRegistry_Ready_Timeout(t *testing.T) {
+	r := health.NewRegistry()
+	block := make(chan struct{})
+	defer close(block)
+	r.Register("stuck", func(ctx context.Context) error {
+		<-block
+		return nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	report := r.Ready(ctx)
+	assert.Equal(t, health.StatusFailing, report.Status)
+	assert.Equal(t, "check didn't finish: context deadline exceeded", report.Checks[0].Error)
+}
+
+func withoutDuration(result health.Result) health.Result {
+	result.DurationMs = 0
+	return result
+}
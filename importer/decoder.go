@@ -0,0 +1,99 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/pkg/errors"
+)
+
+//decoder is what each upload format has to provide, model.CSVDecoder already fits.
+//Next returns recipe or errors of a single row, io.EOF at the end and any other error when upload can't be read further.
+type decoder interface {
+	Next() (*model.Recipe, []model.RowError, error)
+	Line() int
+}
+
+//jsonDecoder reads JSON array element by element, so line here means element number
+type jsonDecoder struct {
+	decoder *json.Decoder
+	line    int
+}
+
+func newJSONDecoder(in io.Reader) (*jsonDecoder, error) {
+	d := json.NewDecoder(in)
+	token, err := d.Token()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read json")
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("json upload has to be an array of recipes")
+	}
+	return &jsonDecoder{decoder: d}, nil
+}
+
+func (d *jsonDecoder) Next() (*model.Recipe, []model.RowError, error) {
+	if !d.decoder.More() {
+		return nil, nil, io.EOF
+	}
+	d.line++
+	recipe := &model.Recipe{}
+	if err := d.decoder.Decode(recipe); err != nil {
+		//after syntax error we don't know where next element starts
+		if _, ok := err.(*json.SyntaxError); ok {
+			return nil, nil, errors.Wrapf(err, "failed to read json element %d", d.line)
+		}
+		return nil, []model.RowError{jsonRowError(d.line, err)}, nil
+	}
+	return recipe, nil, nil
+}
+
+func (d *jsonDecoder) Line() int {
+	return d.line
+}
+
+//ndjsonDecoder reads one recipe per line, empty lines are skipped
+type ndjsonDecoder struct {
+	reader *bufio.Reader
+	line   int
+}
+
+func newNDJSONDecoder(in io.Reader) *ndjsonDecoder {
+	return &ndjsonDecoder{reader: bufio.NewReader(in)}
+}
+
+func (d *ndjsonDecoder) Next() (*model.Recipe, []model.RowError, error) {
+	for {
+		raw, err := d.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, nil, errors.Wrap(err, "failed to read ndjson")
+		}
+		if len(raw) == 0 && err == io.EOF {
+			return nil, nil, io.EOF
+		}
+		d.line++
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 {
+			continue
+		}
+		recipe := &model.Recipe{}
+		if err := json.Unmarshal(raw, recipe); err != nil {
+			return nil, []model.RowError{jsonRowError(d.line, err)}, nil
+		}
+		return recipe, nil, nil
+	}
+}
+
+func (d *ndjsonDecoder) Line() int {
+	return d.line
+}
+
+func jsonRowError(line int, err error) model.RowError {
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+		return model.RowError{Line: line, Column: typeErr.Field, Reason: "expected " + typeErr.Type.String() + ", got " + typeErr.Value}
+	}
+	return model.RowError{Line: line, Reason: errors.Cause(err).Error()}
+}
@@ -0,0 +1,358 @@
+package importer
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+var NotFoundError = errors.New("Import not found")
+var FinishedError = errors.New("Import already finished")
+var QueueFullError = errors.New("Too many imports queued")
+var StoppedError = errors.New("Importer stopped")
+
+type Format string
+
+const (
+	CSV    Format = "csv"
+	JSON   Format = "json"
+	NDJSON Format = "ndjson"
+)
+
+//Semantics decides what happens with recipes which already exist
+type Semantics string
+
+const (
+	//Insert reports existing recipes as row errors
+	Insert Semantics = "insert"
+	//Upsert replaces existing recipes
+	Upsert Semantics = "upsert"
+)
+
+type Status string
+
+const (
+	Queued    Status = "queued"
+	Running   Status = "running"
+	Done      Status = "done"
+	Failed    Status = "failed"
+	Cancelled Status = "cancelled"
+)
+
+//Store is the part of RecipesModel importer needs
+type Store interface {
+	model.RecipesCreator
+	UpsertRecipe(recipe *model.Recipe) error
+	NewCSVDecoder(in io.Reader) (*model.CSVDecoder, []model.RowError, error)
+}
+
+//Job is a snapshot of import progress, Line in Errors is CSV/NDJSON line or JSON array element number
+type Job struct {
+	ID            string           `json:"id"`
+	Status        Status           `json:"status"`
+	Format        Format           `json:"format"`
+	Semantics     Semantics        `json:"semantics"`
+	RowsProcessed int              `json:"rows_processed"`
+	RowsImported  int              `json:"rows_imported"`
+	RowsFailed    int              `json:"rows_failed"`
+	Errors        []model.RowError `json:"errors"`
+	Error         string           `json:"error,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	FinishedAt    *time.Time       `json:"finished_at,omitempty"`
+
+	data   []byte
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (j *Job) finished() bool {
+	return j.Status == Done || j.Status == Failed || j.Status == Cancelled
+}
+
+//snapshot is safe to hand out, importer keeps mutating the original
+func (j *Job) snapshot() Job {
+	s := *j
+	s.Errors = append([]model.RowError{}, j.Errors...)
+	s.data = nil
+	return s
+}
+
+const (
+	defaultKeptJobs = 100
+	defaultJobTTL   = time.Hour
+)
+
+//Importer processes uploads one at a time in a background worker, jobs are kept in memory until evicted, see KeepFinished
+type Importer struct {
+	store   Store
+	mx      sync.Mutex
+	jobs    map[string]*Job
+	lastID  int
+	queue   chan *Job
+	stopped bool
+	wg      sync.WaitGroup
+	//finished are ids of finished jobs in order they finished, oldest are evicted first
+	finished []string
+	keptJobs int
+	jobTTL   time.Duration
+	stats    Stats
+}
+
+type Option func(*Importer)
+
+//KeepFinished replaces default retention of 100 finished jobs for an hour. Older ones are forgotten, Get reports them
+//as not found. Queued and running jobs are always kept.
+func KeepFinished(count int, ttl time.Duration) Option {
+	return func(i *Importer) {
+		i.keptJobs = count
+		i.jobTTL = ttl
+	}
+}
+
+//NewImporter starts the worker, queueSize is how many uploads can wait for it
+func NewImporter(store Store, queueSize int, options ...Option) *Importer {
+	i := &Importer{
+		store:    store,
+		jobs:     make(map[string]*Job),
+		queue:    make(chan *Job, queueSize),
+		keptJobs: defaultKeptJobs,
+		jobTTL:   defaultJobTTL,
+	}
+	for _, option := range options {
+		option(i)
+	}
+	i.wg.Add(1)
+	go i.work()
+	return i
+}
+
+//Submit queues the upload, data has to be whole upload as processing happens after request is gone
+func (i *Importer) Submit(data []byte, format Format, semantics Semantics) (Job, error) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	if i.stopped {
+		return Job{}, StoppedError
+	}
+	i.evict()
+	i.lastID++
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		ID:        strconv.Itoa(i.lastID),
+		Status:    Queued,
+		Format:    format,
+		Semantics: semantics,
+		Errors:    []model.RowError{},
+		CreatedAt: time.Now(),
+		data:      data,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	select {
+	case i.queue <- job:
+	default:
+		cancel()
+		return Job{}, QueueFullError
+	}
+	i.jobs[job.ID] = job
+	return job.snapshot(), nil
+}
+
+func (i *Importer) Get(id string) (Job, error) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	i.evict()
+	if job, ok := i.jobs[id]; ok {
+		return job.snapshot(), nil
+	}
+	return Job{}, NotFoundError
+}
+
+//Cancel stops the job before next row, rows imported so far stay in place
+func (i *Importer) Cancel(id string) (Job, error) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	job, ok := i.jobs[id]
+	if !ok {
+		return Job{}, NotFoundError
+	}
+	if job.finished() {
+		return job.snapshot(), FinishedError
+	}
+	job.cancel()
+	if job.Status == Queued {
+		i.finish(job, Cancelled, nil)
+	}
+	return job.snapshot(), nil
+}
+
+//Stats counts rows of every job so far, evicted ones included, so sums only grow
+type Stats struct {
+	RowsImported int
+	RowsFailed   int
+}
+
+func (i *Importer) Stats() Stats {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	return i.stats
+}
+
+//Check is the health check of importer, it can take uploads until stopped
+func (i *Importer) Check(ctx context.Context) error {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	if i.stopped {
+		return StoppedError
+	}
+	return nil
+}
+
+//Stop cancels all jobs and waits for the worker to quit
+func (i *Importer) Stop() {
+	i.mx.Lock()
+	if i.stopped {
+		i.mx.Unlock()
+		return
+	}
+	i.stopped = true
+	for _, job := range i.jobs {
+		job.cancel()
+	}
+	close(i.queue)
+	i.mx.Unlock()
+	i.wg.Wait()
+}
+
+func (i *Importer) work() {
+	defer i.wg.Done()
+	for job := range i.queue {
+		i.run(job)
+	}
+}
+
+func (i *Importer) run(job *Job) {
+	i.mx.Lock()
+	if job.finished() {
+		i.mx.Unlock()
+		return
+	}
+	job.Status = Running
+	i.mx.Unlock()
+
+	d, err := i.decoder(job)
+	if err != nil {
+		i.mx.Lock()
+		i.finish(job, Failed, err)
+		i.mx.Unlock()
+		return
+	}
+	for {
+		if job.ctx.Err() != nil {
+			i.mx.Lock()
+			i.finish(job, Cancelled, nil)
+			i.mx.Unlock()
+			return
+		}
+		recipe, rowErrors, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			i.mx.Lock()
+			i.finish(job, Failed, err)
+			i.mx.Unlock()
+			return
+		}
+		if recipe != nil {
+			if err := recipe.Validate(); err != nil {
+				rowErrors = err.(*model.ValidationError).RowErrors(d.Line())
+			} else if err := i.save(job.Semantics, recipe); err != nil {
+				rowErrors = []model.RowError{saveRowError(d.Line(), err)}
+			}
+		}
+
+		i.mx.Lock()
+		job.RowsProcessed++
+		if len(rowErrors) > 0 {
+			job.RowsFailed++
+			i.stats.RowsFailed++
+			job.Errors = append(job.Errors, rowErrors...)
+		} else {
+			job.RowsImported++
+			i.stats.RowsImported++
+		}
+		i.mx.Unlock()
+	}
+	i.mx.Lock()
+	i.finish(job, Done, nil)
+	i.mx.Unlock()
+}
+
+func (i *Importer) decoder(job *Job) (decoder, error) {
+	in := bytes.NewReader(job.data)
+	switch job.Format {
+	case CSV:
+		d, headerErrors, err := i.store.NewCSVDecoder(in)
+		if err != nil {
+			i.mx.Lock()
+			job.Errors = append(job.Errors, headerErrors...)
+			i.mx.Unlock()
+			return nil, err
+		}
+		return d, nil
+	case JSON:
+		return newJSONDecoder(in)
+	case NDJSON:
+		return newNDJSONDecoder(in), nil
+	}
+	return nil, errors.Errorf("unknown format: %s", job.Format)
+}
+
+func (i *Importer) save(semantics Semantics, recipe *model.Recipe) error {
+	if semantics == Upsert {
+		return i.store.UpsertRecipe(recipe)
+	}
+	return i.store.CreateRecipe(recipe)
+}
+
+//Must be called with lock held.
+func (i *Importer) finish(job *Job, status Status, err error) {
+	now := time.Now()
+	job.Status = status
+	job.FinishedAt = &now
+	if err != nil {
+		job.Error = err.Error()
+	}
+	job.data = nil
+	job.cancel()
+	i.finished = append(i.finished, job.ID)
+	i.evict()
+}
+
+//evict forgets finished jobs over the count or past ttl. Must be called with lock held.
+func (i *Importer) evict() {
+	deadline := time.Now().Add(-i.jobTTL)
+	n := 0
+	for ; n < len(i.finished); n++ {
+		job := i.jobs[i.finished[n]]
+		if len(i.finished)-n <= i.keptJobs && !job.FinishedAt.Before(deadline) {
+			break
+		}
+		delete(i.jobs, job.ID)
+	}
+	i.finished = i.finished[n:]
+}
+
+func saveRowError(line int, err error) model.RowError {
+	column := "id"
+	if err == model.GoustoReferenceConflictError {
+		column = "gousto_reference"
+	}
+	return model.RowError{Line: line, Column: column, Reason: err.Error()}
+}
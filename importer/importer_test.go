@@ -0,0 +1,164 @@
+package importer_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gobonoid/svc-recipes/importer"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func waitForJob(t *testing.T, i *importer.Importer, id string) importer.Job {
+	for n := 0; n < 100; n++ {
+		job, err := i.Get(id)
+		require.NoError(t, err)
+		if job.FinishedAt != nil {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("import %s didn't finish", id)
+	return importer.Job{}
+}
+
+func TestImporter_CSV_Insert(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "existing"}))
+	i := importer.NewImporter(recipesModel, 1)
+	defer i.Stop()
+
+	job, err := i.Submit([]byte("id,title,calories_kcal\n1,dup,1\n2,ok,2\n3,broken,a lot"), importer.CSV, importer.Insert)
+	require.NoError(t, err)
+	job = waitForJob(t, i, job.ID)
+	assert.Equal(t, importer.Done, job.Status)
+	assert.Equal(t, 3, job.RowsProcessed)
+	assert.Equal(t, 1, job.RowsImported)
+	assert.Equal(t, 2, job.RowsFailed)
+	assert.Equal(t, []model.RowError{
+		{Line: 2, Column: "id", Reason: model.DuplicateError.Error()},
+		{Line: 4, Column: "calories_kcal", Reason: `"a lot" is not a valid integer`},
+	}, job.Errors)
+
+	recipe, err := recipesModel.FetchOneByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, "existing", recipe.Title)
+	assert.Equal(t, importer.Stats{RowsImported: 1, RowsFailed: 2}, i.Stats())
+
+	_, err = i.Cancel(job.ID)
+	assert.Equal(t, importer.FinishedError, err)
+}
+
+func TestImporter_JSON_Upsert(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "existing"}))
+	i := importer.NewImporter(recipesModel, 1)
+	defer i.Stop()
+
+	job, err := i.Submit([]byte(`[{"id": 1, "title": "replaced"}, {"id": 2, "calories_k_cal": "a lot"}, {"id": 3, "title": "new"}]`), importer.JSON, importer.Upsert)
+	require.NoError(t, err)
+	job = waitForJob(t, i, job.ID)
+	assert.Equal(t, importer.Done, job.Status)
+	assert.Equal(t, 2, job.RowsImported)
+	require.Equal(t, 1, len(job.Errors))
+	assert.Equal(t, 2, job.Errors[0].Line)
+	assert.Equal(t, "calories_k_cal", job.Errors[0].Column)
+
+	recipe, err := recipesModel.FetchOneByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, "replaced", recipe.Title)
+}
+
+func TestImporter_NDJSON(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	i := importer.NewImporter(recipesModel, 1)
+	defer i.Stop()
+
+	job, err := i.Submit([]byte("{\"id\": 1, \"title\": \"a\"}\n\n{\"id\": 2\n{\"id\": 3, \"title\": \"b\"}\n"), importer.NDJSON, importer.Insert)
+	require.NoError(t, err)
+	job = waitForJob(t, i, job.ID)
+	assert.Equal(t, importer.Done, job.Status)
+	assert.Equal(t, 2, job.RowsImported)
+	require.Equal(t, 1, len(job.Errors))
+	assert.Equal(t, 3, job.Errors[0].Line)
+}
+
+func TestImporter_InvalidUpload(t *testing.T) {
+	i := importer.NewImporter(model.NewRecipesModel(), 1)
+	defer i.Stop()
+
+	job, err := i.Submit([]byte(`{"id": 1}`), importer.JSON, importer.Insert)
+	require.NoError(t, err)
+	job = waitForJob(t, i, job.ID)
+	assert.Equal(t, importer.Failed, job.Status)
+	assert.NotEmpty(t, job.Error)
+
+	job, err = i.Submit([]byte("id,colour\n1,red"), importer.CSV, importer.Insert)
+	require.NoError(t, err)
+	job = waitForJob(t, i, job.ID)
+	assert.Equal(t, importer.Failed, job.Status)
+	assert.Equal(t, []model.RowError{{Line: 1, Column: "colour", Reason: "unknown column"}, {Line: 1, Column: "title", Reason: "required column missing"}}, job.Errors)
+}
+
+func TestImporter_Cancel(t *testing.T) {
+	i := importer.NewImporter(model.NewRecipesModel(), 2)
+	_, err := i.Cancel("1")
+	assert.Equal(t, importer.NotFoundError, err)
+	assert.NoError(t, i.Check(context.Background()))
+
+	i.Stop()
+	_, err = i.Submit([]byte("id,title\n1,a"), importer.CSV, importer.Insert)
+	assert.Equal(t, importer.StoppedError, err)
+	assert.Equal(t, importer.StoppedError, i.Check(context.Background()))
+}
+
+func TestImporter_Validation(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	i := importer.NewImporter(recipesModel, 1)
+	defer i.Stop()
+
+	job, err := i.Submit([]byte(`[{"id": 1, "title": "ok"}, {"id": 2, "fat_grams": -1}]`), importer.JSON, importer.Insert)
+	require.NoError(t, err)
+	job = waitForJob(t, i, job.ID)
+	assert.Equal(t, 1, job.RowsImported)
+	assert.Equal(t, 1, job.RowsFailed)
+	assert.Equal(t, []model.RowError{
+		{Line: 2, Column: "title", Reason: "is required"},
+		{Line: 2, Column: "fat_grams", Reason: "has to be at least 0"},
+	}, job.Errors)
+}
+
+func TestImporter_KeepFinished(t *testing.T) {
+	i := importer.NewImporter(model.NewRecipesModel(), 1, importer.KeepFinished(2, time.Hour))
+	defer i.Stop()
+
+	var ids []string
+	for n := 1; n <= 3; n++ {
+		job, err := i.Submit([]byte(fmt.Sprintf(`[{"id": %d, "title": "recipe"}]`, n)), importer.JSON, importer.Insert)
+		require.NoError(t, err)
+		waitForJob(t, i, job.ID)
+		ids = append(ids, job.ID)
+	}
+	_, err := i.Get(ids[0])
+	assert.Equal(t, importer.NotFoundError, err, "oldest job is evicted over the count")
+	for _, id := range ids[1:] {
+		_, err := i.Get(id)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, importer.Stats{RowsImported: 3}, i.Stats(), "evicted jobs still count")
+
+	i = importer.NewImporter(model.NewRecipesModel(), 1, importer.KeepFinished(10, time.Millisecond))
+	defer i.Stop()
+	job, err := i.Submit([]byte(`[{"id": 1, "title": "recipe"}]`), importer.JSON, importer.Insert)
+	require.NoError(t, err)
+	//Get can't wait for the job, it may be evicted as soon as it's done
+	for n := 0; n < 100 && i.Stats().RowsImported == 0; n++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	_, err = i.Get(job.ID)
+	assert.Equal(t, importer.NotFoundError, err, "job is evicted past ttl")
+}
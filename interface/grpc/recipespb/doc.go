@@ -0,0 +1,4 @@
+//Package recipespb is generated out of recipes.proto, run go generate after changing it
+package recipespb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative recipes.proto
@@ -0,0 +1,400 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: recipes.proto
+
+// Recipes over gRPC for internal services, shapes follow /v2/recipes of the REST API.
+
+package recipespb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Recipes_GetRecipe_FullMethodName       = "/recipes.v1.Recipes/GetRecipe"
+	Recipes_GetRecipeBySlug_FullMethodName = "/recipes.v1.Recipes/GetRecipeBySlug"
+	Recipes_ListRecipes_FullMethodName     = "/recipes.v1.Recipes/ListRecipes"
+	Recipes_CreateRecipe_FullMethodName    = "/recipes.v1.Recipes/CreateRecipe"
+	Recipes_UpdateRecipe_FullMethodName    = "/recipes.v1.Recipes/UpdateRecipe"
+	Recipes_RateRecipe_FullMethodName      = "/recipes.v1.Recipes/RateRecipe"
+	Recipes_ListRates_FullMethodName       = "/recipes.v1.Recipes/ListRates"
+)
+
+// RecipesClient is the client API for Recipes service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RecipesClient interface {
+	// NOT_FOUND when there's no such recipe
+	GetRecipe(ctx context.Context, in *GetRecipeRequest, opts ...grpc.CallOption) (*Recipe, error)
+	// Old slugs resolve too, compare slug of the returned recipe to find out it was renamed
+	GetRecipeBySlug(ctx context.Context, in *GetRecipeBySlugRequest, opts ...grpc.CallOption) (*Recipe, error)
+	// Streams recipes ordered by id
+	ListRecipes(ctx context.Context, in *ListRecipesRequest, opts ...grpc.CallOption) (Recipes_ListRecipesClient, error)
+	// Id is assigned by the service, INVALID_ARGUMENT carries BadRequest details with every violation
+	CreateRecipe(ctx context.Context, in *CreateRecipeRequest, opts ...grpc.CallOption) (*Recipe, error)
+	// Replaces whole recipe, id is taken from the request not from the recipe
+	UpdateRecipe(ctx context.Context, in *UpdateRecipeRequest, opts ...grpc.CallOption) (*Recipe, error)
+	RateRecipe(ctx context.Context, in *RateRecipeRequest, opts ...grpc.CallOption) (*RateRecipeResponse, error)
+	// Streams rates in the order they were given
+	ListRates(ctx context.Context, in *ListRatesRequest, opts ...grpc.CallOption) (Recipes_ListRatesClient, error)
+}
+
+type recipesClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRecipesClient(cc grpc.ClientConnInterface) RecipesClient {
+	return &recipesClient{cc}
+}
+
+func (c *recipesClient) GetRecipe(ctx context.Context, in *GetRecipeRequest, opts ...grpc.CallOption) (*Recipe, error) {
+	out := new(Recipe)
+	err := c.cc.Invoke(ctx, Recipes_GetRecipe_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipesClient) GetRecipeBySlug(ctx context.Context, in *GetRecipeBySlugRequest, opts ...grpc.CallOption) (*Recipe, error) {
+	out := new(Recipe)
+	err := c.cc.Invoke(ctx, Recipes_GetRecipeBySlug_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipesClient) ListRecipes(ctx context.Context, in *ListRecipesRequest, opts ...grpc.CallOption) (Recipes_ListRecipesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Recipes_ServiceDesc.Streams[0], Recipes_ListRecipes_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &recipesListRecipesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Recipes_ListRecipesClient interface {
+	Recv() (*Recipe, error)
+	grpc.ClientStream
+}
+
+type recipesListRecipesClient struct {
+	grpc.ClientStream
+}
+
+func (x *recipesListRecipesClient) Recv() (*Recipe, error) {
+	m := new(Recipe)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *recipesClient) CreateRecipe(ctx context.Context, in *CreateRecipeRequest, opts ...grpc.CallOption) (*Recipe, error) {
+	out := new(Recipe)
+	err := c.cc.Invoke(ctx, Recipes_CreateRecipe_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipesClient) UpdateRecipe(ctx context.Context, in *UpdateRecipeRequest, opts ...grpc.CallOption) (*Recipe, error) {
+	out := new(Recipe)
+	err := c.cc.Invoke(ctx, Recipes_UpdateRecipe_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipesClient) RateRecipe(ctx context.Context, in *RateRecipeRequest, opts ...grpc.CallOption) (*RateRecipeResponse, error) {
+	out := new(RateRecipeResponse)
+	err := c.cc.Invoke(ctx, Recipes_RateRecipe_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recipesClient) ListRates(ctx context.Context, in *ListRatesRequest, opts ...grpc.CallOption) (Recipes_ListRatesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Recipes_ServiceDesc.Streams[1], Recipes_ListRates_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &recipesListRatesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Recipes_ListRatesClient interface {
+	Recv() (*RecipeRate, error)
+	grpc.ClientStream
+}
+
+type recipesListRatesClient struct {
+	grpc.ClientStream
+}
+
+func (x *recipesListRatesClient) Recv() (*RecipeRate, error) {
+	m := new(RecipeRate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RecipesServer is the server API for Recipes service.
+// All implementations must embed UnimplementedRecipesServer
+// for forward compatibility
+type RecipesServer interface {
+	// NOT_FOUND when there's no such recipe
+	GetRecipe(context.Context, *GetRecipeRequest) (*Recipe, error)
+	// Old slugs resolve too, compare slug of the returned recipe to find out it was renamed
+	GetRecipeBySlug(context.Context, *GetRecipeBySlugRequest) (*Recipe, error)
+	// Streams recipes ordered by id
+	ListRecipes(*ListRecipesRequest, Recipes_ListRecipesServer) error
+	// Id is assigned by the service, INVALID_ARGUMENT carries BadRequest details with every violation
+	CreateRecipe(context.Context, *CreateRecipeRequest) (*Recipe, error)
+	// Replaces whole recipe, id is taken from the request not from the recipe
+	UpdateRecipe(context.Context, *UpdateRecipeRequest) (*Recipe, error)
+	RateRecipe(context.Context, *RateRecipeRequest) (*RateRecipeResponse, error)
+	// Streams rates in the order they were given
+	ListRates(*ListRatesRequest, Recipes_ListRatesServer) error
+	mustEmbedUnimplementedRecipesServer()
+}
+
+// UnimplementedRecipesServer must be embedded to have forward compatible implementations.
+type UnimplementedRecipesServer struct {
+}
+
+func (UnimplementedRecipesServer) GetRecipe(context.Context, *GetRecipeRequest) (*Recipe, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRecipe not implemented")
+}
+func (UnimplementedRecipesServer) GetRecipeBySlug(context.Context, *GetRecipeBySlugRequest) (*Recipe, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRecipeBySlug not implemented")
+}
+func (UnimplementedRecipesServer) ListRecipes(*ListRecipesRequest, Recipes_ListRecipesServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListRecipes not implemented")
+}
+func (UnimplementedRecipesServer) CreateRecipe(context.Context, *CreateRecipeRequest) (*Recipe, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateRecipe not implemented")
+}
+func (UnimplementedRecipesServer) UpdateRecipe(context.Context, *UpdateRecipeRequest) (*Recipe, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateRecipe not implemented")
+}
+func (UnimplementedRecipesServer) RateRecipe(context.Context, *RateRecipeRequest) (*RateRecipeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RateRecipe not implemented")
+}
+func (UnimplementedRecipesServer) ListRates(*ListRatesRequest, Recipes_ListRatesServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListRates not implemented")
+}
+func (UnimplementedRecipesServer) mustEmbedUnimplementedRecipesServer() {}
+
+// UnsafeRecipesServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RecipesServer will
+// result in compilation errors.
+type UnsafeRecipesServer interface {
+	mustEmbedUnimplementedRecipesServer()
+}
+
+func RegisterRecipesServer(s grpc.ServiceRegistrar, srv RecipesServer) {
+	s.RegisterService(&Recipes_ServiceDesc, srv)
+}
+
+func _Recipes_GetRecipe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRecipeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipesServer).GetRecipe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Recipes_GetRecipe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipesServer).GetRecipe(ctx, req.(*GetRecipeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Recipes_GetRecipeBySlug_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRecipeBySlugRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipesServer).GetRecipeBySlug(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Recipes_GetRecipeBySlug_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipesServer).GetRecipeBySlug(ctx, req.(*GetRecipeBySlugRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Recipes_ListRecipes_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListRecipesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RecipesServer).ListRecipes(m, &recipesListRecipesServer{stream})
+}
+
+type Recipes_ListRecipesServer interface {
+	Send(*Recipe) error
+	grpc.ServerStream
+}
+
+type recipesListRecipesServer struct {
+	grpc.ServerStream
+}
+
+func (x *recipesListRecipesServer) Send(m *Recipe) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Recipes_CreateRecipe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRecipeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipesServer).CreateRecipe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Recipes_CreateRecipe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipesServer).CreateRecipe(ctx, req.(*CreateRecipeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Recipes_UpdateRecipe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRecipeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipesServer).UpdateRecipe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Recipes_UpdateRecipe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipesServer).UpdateRecipe(ctx, req.(*UpdateRecipeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Recipes_RateRecipe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RateRecipeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecipesServer).RateRecipe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Recipes_RateRecipe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecipesServer).RateRecipe(ctx, req.(*RateRecipeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Recipes_ListRates_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListRatesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RecipesServer).ListRates(m, &recipesListRatesServer{stream})
+}
+
+type Recipes_ListRatesServer interface {
+	Send(*RecipeRate) error
+	grpc.ServerStream
+}
+
+type recipesListRatesServer struct {
+	grpc.ServerStream
+}
+
+func (x *recipesListRatesServer) Send(m *RecipeRate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Recipes_ServiceDesc is the grpc.ServiceDesc for Recipes service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Recipes_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "recipes.v1.Recipes",
+	HandlerType: (*RecipesServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetRecipe",
+			Handler:    _Recipes_GetRecipe_Handler,
+		},
+		{
+			MethodName: "GetRecipeBySlug",
+			Handler:    _Recipes_GetRecipeBySlug_Handler,
+		},
+		{
+			MethodName: "CreateRecipe",
+			Handler:    _Recipes_CreateRecipe_Handler,
+		},
+		{
+			MethodName: "UpdateRecipe",
+			Handler:    _Recipes_UpdateRecipe_Handler,
+		},
+		{
+			MethodName: "RateRecipe",
+			Handler:    _Recipes_RateRecipe_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListRecipes",
+			Handler:       _Recipes_ListRecipes_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ListRates",
+			Handler:       _Recipes_ListRates_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "recipes.proto",
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gobonoid/svc-recipes/interface/grpc/recipespb"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/gobonoid/svc-recipes/tracing"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//protoFieldNames translates model field names in validation violations, names which are the same are missing
+var protoFieldNames = map[string]string{
+	"uploaded_at":         "updated_at",
+	"calories_k_cal":      "nutrition.calories_kcal",
+	"protein_grams":       "nutrition.protein_grams",
+	"fat_grams":           "nutrition.fat_grams",
+	"carbs_grams":         "nutrition.carbs_grams",
+	"bulletpoint_1":       "bulletpoints",
+	"bulletpoint_2":       "bulletpoints",
+	"bulletpoint_3":       "bulletpoints",
+	"recipe_diet_type_id": "diet_type",
+	"recipe_cuisine":      "cuisine",
+	"in_your_box":         "ingredients",
+}
+
+//recipesService is recipespb.RecipesServer on top of the same model REST API uses
+type recipesService struct {
+	recipespb.UnimplementedRecipesServer
+	recipesAggregator model.RecipesAggregator
+	log               *logrus.Logger
+}
+
+//aggregator traces model calls as part of the call
+func (s *recipesService) aggregator(ctx context.Context) model.RecipesAggregator {
+	return tracing.Model(ctx, s.recipesAggregator)
+}
+
+func (s *recipesService) GetRecipe(ctx context.Context, req *recipespb.GetRecipeRequest) (*recipespb.Recipe, error) {
+	recipe, err := s.aggregator(ctx).FetchOneByID(int(req.Id))
+	if err != nil {
+		return nil, s.statusError(err)
+	}
+	return recipeToProto(recipe), nil
+}
+
+func (s *recipesService) GetRecipeBySlug(ctx context.Context, req *recipespb.GetRecipeBySlugRequest) (*recipespb.Recipe, error) {
+	recipe, err := s.aggregator(ctx).FetchOneBySlug(req.Slug)
+	if err != nil {
+		return nil, s.statusError(err)
+	}
+	return recipeToProto(recipe), nil
+}
+
+func (s *recipesService) ListRecipes(req *recipespb.ListRecipesRequest, stream recipespb.Recipes_ListRecipesServer) error {
+	var recipes []*model.Recipe
+	if req.GoustoReference != nil {
+		recipes = s.aggregator(stream.Context()).FetchByGoustoReference(int(*req.GoustoReference))
+	} else {
+		if req.Limit < 0 || req.Page < 0 {
+			return status.Error(codes.InvalidArgument, "limit and page can't be negative")
+		}
+		page := int(req.Page)
+		if page == 0 {
+			page = 1
+		}
+		recipes = s.aggregator(stream.Context()).FetchRecipes(&model.Limiter{Limit: int(req.Limit), Page: page})
+	}
+	for _, recipe := range recipes {
+		if err := stream.Context().Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		if err := stream.Send(recipeToProto(recipe)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *recipesService) CreateRecipe(ctx context.Context, req *recipespb.CreateRecipeRequest) (*recipespb.Recipe, error) {
+	recipe, err := recipeFromProto(req.Recipe)
+	if err != nil {
+		return nil, s.statusError(err)
+	}
+	recipe.Id = time.Now().Nanosecond()
+	if err := s.aggregator(ctx).CreateRecipe(recipe); err != nil {
+		return nil, s.statusError(err)
+	}
+	return recipeToProto(recipe), nil
+}
+
+func (s *recipesService) UpdateRecipe(ctx context.Context, req *recipespb.UpdateRecipeRequest) (*recipespb.Recipe, error) {
+	recipe, err := recipeFromProto(req.Recipe)
+	if err != nil {
+		return nil, s.statusError(err)
+	}
+	recipe.Id = int(req.Id)
+	if err := s.aggregator(ctx).UpdateRecipe(recipe.Id, recipe); err != nil {
+		return nil, s.statusError(err)
+	}
+	return recipeToProto(recipe), nil
+}
+
+//RateRecipe takes the current time when rate has no rated_at
+func (s *recipesService) RateRecipe(ctx context.Context, req *recipespb.RateRecipeRequest) (*recipespb.RateRecipeResponse, error) {
+	if req.Rate == nil {
+		return nil, status.Error(codes.InvalidArgument, "rate is required")
+	}
+	rate := &model.RecipeRate{Rate: int(req.Rate.Rate), RatedAt: dateTime(req.Rate.RatedAt), RatedBy: req.Rate.RatedBy}
+	if rate.RatedAt.IsZero() {
+		rate.RatedAt = model.DateTime{Time: time.Now()}
+	}
+	if err := s.aggregator(ctx).RateRecipe(int(req.RecipeId), rate); err != nil {
+		return nil, s.statusError(err)
+	}
+	recipe, err := s.aggregator(ctx).FetchOneByID(int(req.RecipeId))
+	if err != nil {
+		return nil, s.statusError(err)
+	}
+	return &recipespb.RateRecipeResponse{AverageRate: recipe.AverageRate}, nil
+}
+
+func (s *recipesService) ListRates(req *recipespb.ListRatesRequest, stream recipespb.Recipes_ListRatesServer) error {
+	rates, err := s.aggregator(stream.Context()).FetchRates(int(req.RecipeId))
+	if err != nil {
+		return s.statusError(err)
+	}
+	for _, rate := range rates {
+		if err := stream.Context().Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		if err := stream.Send(&recipespb.RecipeRate{Rate: int32(rate.Rate), RatedAt: timestamp(rate.RatedAt), RatedBy: rate.RatedBy}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//statusError maps model errors to gRPC codes the way REST API maps them to HTTP statuses.
+//Invalid recipes carry BadRequest details with every violation, unexpected errors are logged and hidden.
+func (s *recipesService) statusError(err error) error {
+	cause := errors.Cause(err)
+	switch cause {
+	case model.NotFoundError:
+		return status.Error(codes.NotFound, err.Error())
+	case model.DuplicateError, model.GoustoReferenceConflictError:
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	if _, ok := status.FromError(cause); ok {
+		return cause
+	}
+	if invalid, ok := cause.(*model.ValidationError); ok {
+		badRequest := &errdetails.BadRequest{}
+		for _, violation := range invalid.Violations {
+			field := violation.Field
+			if name, ok := protoFieldNames[field]; ok {
+				field = name
+			}
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       field,
+				Description: violation.Message,
+			})
+		}
+		st, detailsErr := status.New(codes.InvalidArgument, invalid.Error()).WithDetails(badRequest)
+		if detailsErr != nil {
+			return status.Error(codes.InvalidArgument, invalid.Error())
+		}
+		return st.Err()
+	}
+	s.log.Errorf("%+v", err)
+	return status.Error(codes.Internal, "Internal error")
+}
+
+func recipeToProto(recipe *model.Recipe) *recipespb.Recipe {
+	return &recipespb.Recipe{
+		Id:                   int64(recipe.Id),
+		CreatedAt:            timestamp(recipe.CreatedAt),
+		UpdatedAt:            timestamp(recipe.UpdatedAt),
+		BoxType:              recipe.BoxType,
+		Title:                recipe.Title,
+		Slug:                 recipe.Slug,
+		ShortTitle:           recipe.ShortTitle,
+		MarketingDescription: recipe.MarketingDescription,
+		Nutrition: &recipespb.Nutrition{
+			CaloriesKcal: int32(recipe.CaloriesKCal),
+			ProteinGrams: int32(recipe.ProteinGrams),
+			FatGrams:     int32(recipe.FatGrams),
+			CarbsGrams:   int32(recipe.CarbsGrams),
+		},
+		Bulletpoints:           recipe.Bulletpoints(),
+		DietType:               recipe.RecipeDietTypeId,
+		Season:                 recipe.Season,
+		Base:                   recipe.Base,
+		ProteinSource:          recipe.ProteinSource,
+		PreparationTimeMinutes: int32(recipe.PreparationTimeMinutes),
+		ShelfLifeDays:          int32(recipe.ShelfLifeDays),
+		EquipmentNeeded:        recipe.EquipmentNeeded,
+		OriginCountry:          recipe.OriginCountry,
+		Cuisine:                recipe.RecipeCuisine,
+		Ingredients:            recipe.Ingredients(),
+		GoustoReference:        int64(recipe.GoustoReference),
+		AverageRate:            recipe.AverageRate,
+	}
+}
+
+//recipeFromProto returns valid recipe only, id and average rate are left out
+func recipeFromProto(r *recipespb.Recipe) (*model.Recipe, error) {
+	if r == nil {
+		return nil, status.Error(codes.InvalidArgument, "recipe is required")
+	}
+	nutrition := r.Nutrition
+	if nutrition == nil {
+		nutrition = &recipespb.Nutrition{}
+	}
+	recipe := &model.Recipe{
+		CreatedAt:              dateTime(r.CreatedAt),
+		UpdatedAt:              dateTime(r.UpdatedAt),
+		BoxType:                r.BoxType,
+		Title:                  r.Title,
+		Slug:                   r.Slug,
+		ShortTitle:             r.ShortTitle,
+		MarketingDescription:   r.MarketingDescription,
+		CaloriesKCal:           int(nutrition.CaloriesKcal),
+		ProteinGrams:           int(nutrition.ProteinGrams),
+		FatGrams:               int(nutrition.FatGrams),
+		CarbsGrams:             int(nutrition.CarbsGrams),
+		RecipeDietTypeId:       r.DietType,
+		Season:                 r.Season,
+		Base:                   r.Base,
+		ProteinSource:          r.ProteinSource,
+		PreparationTimeMinutes: int(r.PreparationTimeMinutes),
+		ShelfLifeDays:          int(r.ShelfLifeDays),
+		EquipmentNeeded:        r.EquipmentNeeded,
+		OriginCountry:          r.OriginCountry,
+		RecipeCuisine:          r.Cuisine,
+		GoustoReference:        int(r.GoustoReference),
+	}
+	if err := recipe.SetBulletpoints(r.Bulletpoints); err != nil {
+		return nil, err
+	}
+	recipe.SetIngredients(r.Ingredients)
+	if err := recipe.Validate(); err != nil {
+		return nil, err
+	}
+	return recipe, nil
+}
+
+//timestamp is nil for zero date, the same as JSON null
+func timestamp(date model.DateTime) *timestamppb.Timestamp {
+	if date.IsZero() {
+		return nil
+	}
+	return timestamppb.New(date.Time)
+}
+
+func dateTime(ts *timestamppb.Timestamp) model.DateTime {
+	if ts == nil {
+		return model.DateTime{}
+	}
+	return model.DateTime{Time: ts.AsTime()}
+}
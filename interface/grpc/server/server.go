@@ -0,0 +1,169 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gobonoid/svc-recipes/interface/grpc/recipespb"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/gobonoid/svc-recipes/tracing"
+	"github.com/pkg/errors"
+	otelCodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+//RecipesServer is gRPC counterpart of REST server.RecipesServer, it runs on its own port next to it
+type RecipesServer struct {
+	server *grpc.Server
+	port   int
+	log    *logrus.Logger
+}
+
+func NewRecipesServer(port int, log *logrus.Logger, recipesAggregator model.RecipesAggregator) *RecipesServer {
+	s := &RecipesServer{port: port, log: log}
+	s.server = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	)
+	recipespb.RegisterRecipesServer(s.server, &recipesService{recipesAggregator: recipesAggregator, log: log})
+	return s
+}
+
+func (s *RecipesServer) Start() {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+	if err != nil {
+		s.log.Fatal(errors.Wrap(err, "Failed to start gRPC Recipes Server"))
+	}
+	s.serve(listener)
+}
+
+//serve is Start on a given listener, tests use in memory one
+func (s *RecipesServer) serve(listener net.Listener) {
+	go func() {
+		if err := s.server.Serve(listener); err != nil {
+			s.log.Fatal(errors.Wrap(err, "Failed to start gRPC Recipes Server"))
+		}
+	}()
+	s.log.Info("gRPC Recipes Server started")
+}
+
+//Stop lets running calls, streams included, finish until ctx is done and cancels the ones which don't
+func (s *RecipesServer) Stop(ctx context.Context) {
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.log.Warn("gRPC Recipes Server didn't stop gracefully, cancelling running calls")
+		s.server.Stop()
+		<-stopped
+	}
+}
+
+func (s *RecipesServer) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	start := time.Now()
+	ctx, span := startSpan(ctx, info.FullMethod)
+	defer func() {
+		if r := recover(); r != nil {
+			err = s.recovered(ctx, r)
+		}
+		endSpan(span, err)
+		s.logCall(ctx, info.FullMethod, start, err)
+	}()
+	return handler(ctx, req)
+}
+
+func (s *RecipesServer) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	start := time.Now()
+	ctx, span := startSpan(stream.Context(), info.FullMethod)
+	defer func() {
+		if r := recover(); r != nil {
+			err = s.recovered(ctx, r)
+		}
+		endSpan(span, err)
+		s.logCall(ctx, info.FullMethod, start, err)
+	}()
+	return handler(srv, tracedStream{ServerStream: stream, ctx: ctx})
+}
+
+//tracedStream hands the context with call span to stream handlers
+type tracedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s tracedStream) Context() context.Context {
+	return s.ctx
+}
+
+//metadataCarrier lets tracing.Propagator read traceparent from incoming metadata, the same key REST takes as header
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+//startSpan continues the trace of the caller, span is named package.Service/Method after full method
+func startSpan(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = tracing.Propagator.Extract(ctx, metadataCarrier(md))
+	name := strings.TrimPrefix(fullMethod, "/")
+	service, method := path.Split(name)
+	return tracing.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.RPCSystemGRPC, semconv.RPCService(strings.TrimSuffix(service, "/")), semconv.RPCMethod(method)),
+	)
+}
+
+//endSpan marks the span failed on the codes which mean the server failed, like 5xx of REST
+func endSpan(span trace.Span, err error) {
+	code := status.Code(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+	switch code {
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss, codes.Unimplemented:
+		span.SetStatus(otelCodes.Error, code.String())
+	}
+	span.End()
+}
+
+//recovered is what echo Recover middleware does for REST - panic is logged and the call fails as internal error
+func (s *RecipesServer) recovered(ctx context.Context, r interface{}) error {
+	s.log.WithFields(tracing.LogFields(ctx)).Errorf("[PANIC RECOVER] %v", r)
+	return status.Error(codes.Internal, "Internal error")
+}
+
+func (s *RecipesServer) logCall(ctx context.Context, method string, start time.Time, err error) {
+	s.log.WithFields(tracing.LogFields(ctx)).WithFields(logrus.Fields{
+		"method":  method,
+		"code":    status.Code(err).String(),
+		"latency": time.Since(start).String(),
+	}).Info("gRPC call handled")
+}
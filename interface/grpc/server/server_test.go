@@ -0,0 +1,193 @@
+package server
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gobonoid/svc-recipes/interface/grpc/recipespb"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T) (recipespb.RecipesClient, *RecipesServer, *grpc.ClientConn) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Chilli", GoustoReference: 59, InYourBox: "pork, beans"}))
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 2, Title: "Fish Pie"}))
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 3, Title: "Lamb Curry"}))
+
+	listener := bufconn.Listen(1 << 20)
+	s := NewRecipesServer(0, logrus.New(), recipesModel)
+	s.serve(listener)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	return recipespb.NewRecipesClient(conn), s, conn
+}
+
+func TestRecipesServer_GetRecipe(t *testing.T) {
+	client, s, conn := newTestClient(t)
+	defer s.Stop(context.Background())
+	defer conn.Close()
+
+	recipe, err := client.GetRecipe(context.Background(), &recipespb.GetRecipeRequest{Id: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "Pork Chilli", recipe.Title)
+	assert.Equal(t, []string{"pork", "beans"}, recipe.Ingredients)
+	assert.Nil(t, recipe.CreatedAt)
+
+	_, err = client.GetRecipe(context.Background(), &recipespb.GetRecipeRequest{Id: 42})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestRecipesServer_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	client, s, conn := newTestClient(t)
+	defer s.Stop(context.Background())
+	defer conn.Close()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, err := client.GetRecipe(ctx, &recipespb.GetRecipeRequest{Id: 1})
+	require.NoError(t, err)
+	stream, err := client.ListRates(ctx, &recipespb.ListRatesRequest{RecipeId: 1})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, io.EOF, err)
+
+	spans := recorder.Ended()
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String(), span.Name())
+	}
+	require.Equal(t, []string{
+		"RecipesModel.FetchOneByID", "recipes.v1.Recipes/GetRecipe",
+		"RecipesModel.FetchRates", "recipes.v1.Recipes/ListRates",
+	}, names)
+	assert.Equal(t, "00f067aa0ba902b7", spans[1].Parent().SpanID().String(), "caller span")
+	assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, spans[3].SpanContext().SpanID(), spans[2].Parent().SpanID())
+}
+
+func TestRecipesServer_ListRecipes(t *testing.T) {
+	client, s, conn := newTestClient(t)
+	defer s.Stop(context.Background())
+	defer conn.Close()
+
+	for _, tc := range []struct {
+		request *recipespb.ListRecipesRequest
+		ids     []int64
+	}{
+		{&recipespb.ListRecipesRequest{}, []int64{1, 2, 3}},
+		{&recipespb.ListRecipesRequest{Limit: 2, Page: 2}, []int64{3}},
+		{&recipespb.ListRecipesRequest{Limit: 2, Page: 5}, nil},
+		{&recipespb.ListRecipesRequest{GoustoReference: new(int64)}, nil},
+	} {
+		stream, err := client.ListRecipes(context.Background(), tc.request)
+		require.NoError(t, err)
+		var ids []int64
+		for {
+			recipe, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			ids = append(ids, recipe.Id)
+		}
+		assert.Equal(t, tc.ids, ids, "%v", tc.request)
+	}
+}
+
+func TestRecipesServer_CreateRecipe_Validation(t *testing.T) {
+	client, s, conn := newTestClient(t)
+	defer s.Stop(context.Background())
+	defer conn.Close()
+
+	_, err := client.CreateRecipe(context.Background(), &recipespb.CreateRecipeRequest{Recipe: &recipespb.Recipe{
+		Nutrition: &recipespb.Nutrition{FatGrams: -1},
+	}})
+	st := status.Convert(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Equal(t, 1, len(st.Details()))
+	var fields []string
+	for _, violation := range st.Details()[0].(*errdetails.BadRequest).FieldViolations {
+		fields = append(fields, violation.Field)
+	}
+	assert.Equal(t, []string{"title", "nutrition.fat_grams"}, fields)
+
+	recipe, err := client.CreateRecipe(context.Background(), &recipespb.CreateRecipeRequest{Recipe: &recipespb.Recipe{
+		Title:        "Beef Stew",
+		Bulletpoints: []string{"hearty"},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "beef-stew", recipe.Slug)
+	assert.Equal(t, []string{"hearty"}, recipe.Bulletpoints)
+}
+
+func TestRecipesServer_Rates(t *testing.T) {
+	client, s, conn := newTestClient(t)
+	defer s.Stop(context.Background())
+	defer conn.Close()
+
+	for _, rate := range []int32{3, 4} {
+		_, err := client.RateRecipe(context.Background(), &recipespb.RateRecipeRequest{RecipeId: 2, Rate: &recipespb.RecipeRate{Rate: rate, RatedBy: "anna"}})
+		require.NoError(t, err)
+	}
+	response, err := client.RateRecipe(context.Background(), &recipespb.RateRecipeRequest{RecipeId: 2, Rate: &recipespb.RecipeRate{Rate: 5}})
+	require.NoError(t, err)
+	assert.Equal(t, float32(4), response.AverageRate)
+
+	stream, err := client.ListRates(context.Background(), &recipespb.ListRatesRequest{RecipeId: 2})
+	require.NoError(t, err)
+	var rates []int32
+	for {
+		rate, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		assert.NotNil(t, rate.RatedAt)
+		rates = append(rates, rate.Rate)
+	}
+	assert.Equal(t, []int32{3, 4, 5}, rates)
+
+	_, err = client.RateRecipe(context.Background(), &recipespb.RateRecipeRequest{RecipeId: 42, Rate: &recipespb.RecipeRate{Rate: 5}})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestRecipesServer_Stop(t *testing.T) {
+	client, s, conn := newTestClient(t)
+	defer conn.Close()
+
+	stream, err := client.ListRecipes(context.Background(), &recipespb.ListRecipesRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.NoError(t, err)
+	s.Stop(context.Background())
+
+	//calls started before Stop are finished, new ones are refused
+	for {
+		if _, err = stream.Recv(); err != nil {
+			break
+		}
+	}
+	assert.Equal(t, io.EOF, err)
+	_, err = client.GetRecipe(context.Background(), &recipespb.GetRecipeRequest{Id: 1})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
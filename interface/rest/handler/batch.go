@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/labstack/echo"
+)
+
+const (
+	//Method is the route param of custom methods like /recipes:batch, echo treats ':' as param start
+	//so the param holds whole suffix including the colon
+	Method = "method"
+
+	batchMethod        = ":batch"
+	maxBatchOperations = 100
+)
+
+type BatchWriteRequest struct {
+	XMLName    xml.Name               `json:"-" xml:"batch"`
+	Operations []model.BatchOperation `json:"operations" xml:"operations>operation"`
+}
+
+//BatchWriteResponse has one result per operation in the same order, Applied is false when whole batch was rolled back
+type BatchWriteResponse struct {
+	XMLName xml.Name            `json:"-" xml:"batch"`
+	Applied bool                `json:"applied" xml:"applied"`
+	Results []model.BatchResult `json:"results" xml:"results>result"`
+}
+
+//RecipesMethod dispatches custom methods of recipes collection, POST /recipes:batch is the only one so far
+func (h RecipesHandler) RecipesMethod(c echo.Context) error {
+	if c.Param(Method) == batchMethod {
+		return h.BatchRecipes(c)
+	}
+	return echo.ErrNotFound
+}
+
+//BatchRecipes applies create/update/delete operations all or nothing, 409 with per operation results means nothing changed
+func (h RecipesHandler) BatchRecipes(c echo.Context) error {
+	request := &BatchWriteRequest{}
+	if err := bind(c, request); err != nil {
+		return err
+	}
+	if len(request.Operations) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "No operations given")
+	}
+	if len(request.Operations) > maxBatchOperations {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("At most %d operations can be applied at once", maxBatchOperations))
+	}
+	results, err := h.aggregator(c).ApplyBatch(request.Operations)
+	if err == model.BatchAbortedError {
+		return respond(c, http.StatusConflict, BatchWriteResponse{Results: results})
+	}
+	return respond(c, http.StatusOK, BatchWriteResponse{Applied: true, Results: results})
+}
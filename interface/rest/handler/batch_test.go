@@ -0,0 +1,69 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gobonoid/svc-recipes/interface/rest/handler"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func batch(t *testing.T, h handler.RecipesHandler, body string) (*httptest.ResponseRecorder, error) {
+	e := echo.New()
+	req := httptest.NewRequest(echo.POST, "/recipes:batch", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames(handler.Method)
+	c.SetParamValues(":batch")
+	return rec, h.RecipesMethod(c)
+}
+
+func TestRecipesHandler_BatchRecipes(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Chilli"}))
+	h := handler.NewRecipesHandler(recipesModel)
+
+	rec, err := batch(t, h, `{"operations": [
+		{"op": "create", "id": 2, "recipe": {"title": "Fish Pie"}},
+		{"op": "delete", "id": 1}
+	]}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var response handler.BatchWriteResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.True(t, response.Applied)
+	assert.Equal(t, 2, len(response.Results))
+	_, err = recipesModel.FetchOneByID(2)
+	assert.NoError(t, err)
+}
+
+func TestRecipesHandler_BatchRecipes_Conflict(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Chilli"}))
+	h := handler.NewRecipesHandler(recipesModel)
+
+	rec, err := batch(t, h, `{"operations": [
+		{"op": "delete", "id": 1},
+		{"op": "update", "id": 5, "recipe": {"title": "Fish Pie"}}
+	]}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	var response handler.BatchWriteResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.False(t, response.Applied)
+	require.Equal(t, 2, len(response.Results))
+	assert.Equal(t, model.BatchRolledBack, response.Results[0].Status)
+	assert.Equal(t, model.BatchFailed, response.Results[1].Status)
+	_, err = recipesModel.FetchOneByID(1)
+	assert.NoError(t, err)
+
+	_, err = batch(t, h, `{"operations": []}`)
+	assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+}
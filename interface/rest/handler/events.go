@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/labstack/echo"
+)
+
+const (
+	MIMETextEventStream = "text/event-stream"
+	HeaderLastEventID   = "Last-Event-ID"
+
+	//EventReset tells subscriber some events were lost and it has to resync, events after it follow as usual
+	EventReset = "reset"
+
+	//heartbeatInterval keeps proxies from closing idle streams
+	heartbeatInterval = 15 * time.Second
+)
+
+//StreamEvents serves recipe changes as Server-Sent Events, resuming after Last-Event-ID when given. Stream ends when
+//stop is closed, so server shutdown doesn't wait for subscribers.
+func (h RecipesHandler) StreamEvents(stop <-chan struct{}) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var lastEventID int64
+		if id := c.Request().Header.Get(HeaderLastEventID); id != "" {
+			var err error
+			if lastEventID, err = strconv.ParseInt(id, 10, 64); err != nil || lastEventID < 0 {
+				return echo.NewHTTPError(http.StatusBadRequest, "Incorrect Last-Event-ID given")
+			}
+		}
+		subscription := h.recipesAggregator.SubscribeEvents(lastEventID)
+		defer subscription.Close()
+
+		res := c.Response()
+		res.Header().Set(echo.HeaderContentType, MIMETextEventStream)
+		res.Header().Set("Cache-Control", "no-cache")
+		res.Header().Set("X-Accel-Buffering", "no")
+		res.WriteHeader(http.StatusOK)
+		if subscription.Lost {
+			fmt.Fprintf(res, "event: %s\ndata: {}\n\n", EventReset)
+		}
+		for _, event := range subscription.Backlog {
+			if err := writeEvent(res, event); err != nil {
+				return nil
+			}
+		}
+		res.Flush()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case event, ok := <-subscription.Events:
+				//subscriber fell behind, it reconnects with Last-Event-ID and gets the rest from the backlog
+				if !ok {
+					return nil
+				}
+				if err := writeEvent(res, event); err != nil {
+					return nil
+				}
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+					return nil
+				}
+			case <-c.Request().Context().Done():
+				return nil
+			case <-stop:
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
+
+func writeEvent(res *echo.Response, event model.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -0,0 +1,95 @@
+package handler_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gobonoid/svc-recipes/interface/rest/handler"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//readEvents reads n events from the stream, each as its lines joined with |
+func readEvents(t *testing.T, r *bufio.Reader, n int) []string {
+	var events []string
+	var lines []string
+	for len(events) < n {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line != "" {
+			lines = append(lines, line)
+			continue
+		}
+		events = append(events, strings.Join(lines, "|"))
+		lines = nil
+	}
+	return events
+}
+
+func TestRecipesHandler_StreamEvents(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Chilli"}))
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 2, Title: "Fish Pie"}))
+	stop := make(chan struct{})
+	e := echo.New()
+	e.HTTPErrorHandler = handler.HTTPErrorHandler
+	e.GET("/recipes/events", handler.NewRecipesHandler(recipesModel).StreamEvents(stop))
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	req, err := http.NewRequest(echo.GET, ts.URL+"/recipes/events", nil)
+	require.NoError(t, err)
+	req.Header.Set(handler.HeaderLastEventID, "1")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, handler.MIMETextEventStream, res.Header.Get(echo.HeaderContentType))
+	body := bufio.NewReader(res.Body)
+
+	events := readEvents(t, body, 1)
+	assert.True(t, strings.HasPrefix(events[0], `id: 2|event: created|data: {"id":2,"type":"created","recipe_id":2,`), events[0])
+
+	require.NoError(t, recipesModel.RateRecipe(1, &model.RecipeRate{Rate: 5}))
+	events = readEvents(t, body, 1)
+	assert.True(t, strings.HasPrefix(events[0], "id: 3|event: rated|"), events[0])
+	assert.Contains(t, events[0], `"AverageRate":5`)
+
+	//stream ends when server stops
+	close(stop)
+	_, err = body.ReadString('\n')
+	assert.NotNil(t, err)
+}
+
+func TestRecipesHandler_StreamEvents_Reset(t *testing.T) {
+	recipesModel := model.NewRecipesModel(model.EventsCapacity(1))
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Chilli"}))
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 2, Title: "Fish Pie"}))
+	stop := make(chan struct{})
+	e := echo.New()
+	e.HTTPErrorHandler = handler.HTTPErrorHandler
+	e.GET("/recipes/events", handler.NewRecipesHandler(recipesModel).StreamEvents(stop))
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+	defer close(stop)
+
+	res, err := http.Get(ts.URL + "/recipes/events")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	events := readEvents(t, bufio.NewReader(res.Body), 2)
+	assert.Equal(t, "event: reset|data: {}", events[0])
+	assert.True(t, strings.HasPrefix(events[1], "id: 2|"), events[1])
+
+	req, err := http.NewRequest(echo.GET, ts.URL+"/recipes/events", nil)
+	require.NoError(t, err)
+	req.Header.Set(handler.HeaderLastEventID, "first")
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+)
+
+const (
+	Format = "format"
+
+	//flushEvery decides how many recipes are written before response is flushed to the client
+	flushEvery = 100
+)
+
+//recipesEncoder writes recipes one by one, so export never has whole catalogue serialized in memory.
+//Flush pushes buffered recipes out, Close finishes the document.
+type recipesEncoder interface {
+	Encode(recipe *model.Recipe) error
+	Flush() error
+	Close() error
+}
+
+type csvEncoder struct {
+	*model.CSVEncoder
+}
+
+func (e csvEncoder) Close() error {
+	return e.Flush()
+}
+
+type jsonArrayEncoder struct {
+	w       io.Writer
+	encoder *json.Encoder
+	started bool
+}
+
+func newJSONArrayEncoder(w io.Writer) *jsonArrayEncoder {
+	return &jsonArrayEncoder{w: w, encoder: json.NewEncoder(w)}
+}
+
+func (e *jsonArrayEncoder) Encode(recipe *model.Recipe) error {
+	separator := ","
+	if !e.started {
+		separator = "["
+		e.started = true
+	}
+	if _, err := io.WriteString(e.w, separator); err != nil {
+		return err
+	}
+	return e.encoder.Encode(recipe)
+}
+
+func (e *jsonArrayEncoder) Flush() error {
+	return nil
+}
+
+func (e *jsonArrayEncoder) Close() error {
+	end := "]"
+	if !e.started {
+		end = "[]"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+type ndjsonEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(recipe *model.Recipe) error {
+	return e.encoder.Encode(recipe)
+}
+
+func (e *ndjsonEncoder) Flush() error {
+	return nil
+}
+
+func (e *ndjsonEncoder) Close() error {
+	return nil
+}
+
+//ExportRecipes streams the whole catalogue, ?gousto_reference=59 narrows it down the same way list does
+func (h RecipesHandler) ExportRecipes(c echo.Context) error {
+	format := c.QueryParam(Format)
+	if format == "" {
+		format = "json"
+	}
+	var contentType string
+	switch format {
+	case "csv":
+		contentType = MIMETextCSV
+	case "json":
+		contentType = echo.MIMEApplicationJSONCharsetUTF8
+	case "ndjson":
+		contentType = MIMEApplicationNDJSON
+	case "parquet":
+		contentType = MIMEApplicationParquet
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "Format has to be one of csv, json, ndjson, parquet")
+	}
+
+	var recipes []*model.Recipe
+	if ref := c.QueryParam(GoustoReference); ref != "" {
+		reference, err := strconv.Atoi(ref)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Incorrect gousto_reference given")
+		}
+		recipes = h.aggregator(c).FetchByGoustoReference(reference)
+	} else {
+		recipes = h.aggregator(c).FetchRecipes(&model.Limiter{})
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.Header().Set(echo.HeaderContentDisposition, "attachment; filename=recipes."+format)
+	res.WriteHeader(http.StatusOK)
+
+	var encoder recipesEncoder
+	var err error
+	switch format {
+	case "csv":
+		var e *model.CSVEncoder
+		e, err = model.NewCSVEncoder(res)
+		encoder = csvEncoder{e}
+	case "json":
+		encoder = newJSONArrayEncoder(res)
+	case "ndjson":
+		encoder = &ndjsonEncoder{encoder: json.NewEncoder(res)}
+	case "parquet":
+		encoder, err = newParquetEncoder(res)
+	}
+	if err != nil {
+		//headers are gone already, all we can do is log it
+		logger(c).Errorf("%#v", errors.Wrap(err, "Failed to start export"))
+		return nil
+	}
+	for i, recipe := range recipes {
+		if err := encoder.Encode(recipe); err != nil {
+			logger(c).Errorf("%#v", errors.Wrap(err, "Failed to export recipe"))
+			return nil
+		}
+		if (i+1)%flushEvery == 0 {
+			if err := encoder.Flush(); err != nil {
+				logger(c).Errorf("%#v", errors.Wrap(err, "Failed to export recipes"))
+				return nil
+			}
+			res.Flush()
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		logger(c).Errorf("%#v", errors.Wrap(err, "Failed to finish export"))
+	}
+	return nil
+}
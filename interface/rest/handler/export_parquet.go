@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"io"
+
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/pkg/errors"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+const MIMEApplicationParquet = "application/vnd.apache.parquet"
+
+//parquetRecipe is flat copy of model.Recipe, dates are kept as unix millis so analytics can treat them as timestamps.
+//Dates not set are null, like in JSON.
+type parquetRecipe struct {
+	Id                     int64   `parquet:"name=id, type=INT64"`
+	CreatedAt              *int64  `parquet:"name=created_at, type=INT64, convertedtype=TIMESTAMP_MILLIS, repetitiontype=OPTIONAL"`
+	UpdatedAt              *int64  `parquet:"name=updated_at, type=INT64, convertedtype=TIMESTAMP_MILLIS, repetitiontype=OPTIONAL"`
+	BoxType                string  `parquet:"name=box_type, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Title                  string  `parquet:"name=title, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Slug                   string  `parquet:"name=slug, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ShortTitle             string  `parquet:"name=short_title, type=BYTE_ARRAY, convertedtype=UTF8"`
+	MarketingDescription   string  `parquet:"name=marketing_description, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CaloriesKCal           int64   `parquet:"name=calories_kcal, type=INT64"`
+	ProteinGrams           int64   `parquet:"name=protein_grams, type=INT64"`
+	FatGrams               int64   `parquet:"name=fat_grams, type=INT64"`
+	CarbsGrams             int64   `parquet:"name=carbs_grams, type=INT64"`
+	Bulletpoint1           string  `parquet:"name=bulletpoint1, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Bulletpoint2           string  `parquet:"name=bulletpoint2, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Bulletpoint3           string  `parquet:"name=bulletpoint3, type=BYTE_ARRAY, convertedtype=UTF8"`
+	RecipeDietTypeId       string  `parquet:"name=recipe_diet_type_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Season                 string  `parquet:"name=season, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Base                   string  `parquet:"name=base, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ProteinSource          string  `parquet:"name=protein_source, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PreparationTimeMinutes int64   `parquet:"name=preparation_time_minutes, type=INT64"`
+	ShelfLifeDays          int64   `parquet:"name=shelf_life_days, type=INT64"`
+	EquipmentNeeded        string  `parquet:"name=equipment_needed, type=BYTE_ARRAY, convertedtype=UTF8"`
+	OriginCountry          string  `parquet:"name=origin_country, type=BYTE_ARRAY, convertedtype=UTF8"`
+	RecipeCuisine          string  `parquet:"name=recipe_cuisine, type=BYTE_ARRAY, convertedtype=UTF8"`
+	InYourBox              string  `parquet:"name=in_your_box, type=BYTE_ARRAY, convertedtype=UTF8"`
+	GoustoReference        int64   `parquet:"name=gousto_reference, type=INT64"`
+	AverageRate            float32 `parquet:"name=average_rate, type=FLOAT"`
+}
+
+//parquetEncoder leaves row groups to the writer, Flush would otherwise produce tiny ones
+type parquetEncoder struct {
+	writer *writer.ParquetWriter
+}
+
+func newParquetEncoder(w io.Writer) (*parquetEncoder, error) {
+	pw, err := writer.NewParquetWriterFromWriter(w, new(parquetRecipe), 1)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create parquet writer")
+	}
+	return &parquetEncoder{writer: pw}, nil
+}
+
+func (e *parquetEncoder) Encode(r *model.Recipe) error {
+	return e.writer.Write(parquetRecipe{
+		Id:                     int64(r.Id),
+		CreatedAt:              unixMillis(r.CreatedAt),
+		UpdatedAt:              unixMillis(r.UpdatedAt),
+		BoxType:                r.BoxType,
+		Title:                  r.Title,
+		Slug:                   r.Slug,
+		ShortTitle:             r.ShortTitle,
+		MarketingDescription:   r.MarketingDescription,
+		CaloriesKCal:           int64(r.CaloriesKCal),
+		ProteinGrams:           int64(r.ProteinGrams),
+		FatGrams:               int64(r.FatGrams),
+		CarbsGrams:             int64(r.CarbsGrams),
+		Bulletpoint1:           r.Bulletpoint1,
+		Bulletpoint2:           r.Bulletpoint2,
+		Bulletpoint3:           r.Bulletpoint3,
+		RecipeDietTypeId:       r.RecipeDietTypeId,
+		Season:                 r.Season,
+		Base:                   r.Base,
+		ProteinSource:          r.ProteinSource,
+		PreparationTimeMinutes: int64(r.PreparationTimeMinutes),
+		ShelfLifeDays:          int64(r.ShelfLifeDays),
+		EquipmentNeeded:        r.EquipmentNeeded,
+		OriginCountry:          r.OriginCountry,
+		RecipeCuisine:          r.RecipeCuisine,
+		InYourBox:              r.InYourBox,
+		GoustoReference:        int64(r.GoustoReference),
+		AverageRate:            r.AverageRate,
+	})
+}
+
+func (e *parquetEncoder) Flush() error {
+	return nil
+}
+
+func (e *parquetEncoder) Close() error {
+	return e.writer.WriteStop()
+}
+
+//unixMillis is nil for zero date, UnixNano of which overflows
+func unixMillis(date model.DateTime) *int64 {
+	if date.IsZero() {
+		return nil
+	}
+	millis := date.Unix()*1e3 + int64(date.Nanosecond())/1e6
+	return &millis
+}
@@ -0,0 +1,99 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobonoid/svc-recipes/interface/rest/handler"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+//parquetDates reads date columns of parquet export back
+type parquetDates struct {
+	CreatedAt *int64 `parquet:"name=created_at, type=INT64, convertedtype=TIMESTAMP_MILLIS, repetitiontype=OPTIONAL"`
+	UpdatedAt *int64 `parquet:"name=updated_at, type=INT64, convertedtype=TIMESTAMP_MILLIS, repetitiontype=OPTIONAL"`
+}
+
+const exportCSV = `id,created_at,updated_at,title,gousto_reference
+1,30/06/2015 17:58:00,01/07/2015 10:00:00,Pork Chilli,59
+2,30/06/2015 17:58:00,30/06/2015 17:58:00,"Fish, Chips",60`
+
+func export(t *testing.T, h handler.RecipesHandler, query string) *httptest.ResponseRecorder {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	require.NoError(t, h.ExportRecipes(e.NewContext(httptest.NewRequest(echo.GET, "/recipes/export?"+query, nil), rec)))
+	return rec
+}
+
+func TestRecipesHandler_ExportRecipes_CSV(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.LoadFromCSV(strings.NewReader(exportCSV)))
+
+	rec := export(t, handler.NewRecipesHandler(recipesModel), "format=csv")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, handler.MIMETextCSV, rec.Header().Get(echo.HeaderContentType))
+
+	reimported := model.NewRecipesModel()
+	require.NoError(t, reimported.LoadFromCSV(rec.Body))
+	assert.Equal(t, recipesModel.FetchRecipes(&model.Limiter{}), reimported.FetchRecipes(&model.Limiter{}))
+}
+
+func TestRecipesHandler_ExportRecipes_JSON(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	h := handler.NewRecipesHandler(recipesModel)
+	assert.Equal(t, "[]", export(t, h, "").Body.String())
+
+	require.NoError(t, recipesModel.LoadFromCSV(strings.NewReader(exportCSV)))
+	var recipes []map[string]interface{}
+	require.NoError(t, json.Unmarshal(export(t, h, "format=json").Body.Bytes(), &recipes))
+	assert.Equal(t, 2, len(recipes))
+
+	lines := strings.Split(strings.TrimSpace(export(t, h, "format=ndjson&gousto_reference=60").Body.String()), "\n")
+	require.Equal(t, 1, len(lines))
+	assert.Contains(t, lines[0], `"title":"Fish, Chips"`)
+}
+
+func TestRecipesHandler_ExportRecipes_Parquet(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.LoadFromCSV(strings.NewReader(exportCSV)))
+	rec := export(t, handler.NewRecipesHandler(recipesModel), "format=parquet")
+	assert.Equal(t, handler.MIMEApplicationParquet, rec.Header().Get(echo.HeaderContentType))
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "PAR1"))
+	assert.True(t, strings.HasSuffix(rec.Body.String(), "PAR1"))
+}
+
+func TestRecipesHandler_ExportRecipes_ParquetZeroDates(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.LoadFromCSV(strings.NewReader(exportCSV)))
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 3, Title: "Created Over API"}))
+	rec := export(t, handler.NewRecipesHandler(recipesModel), "format=parquet")
+
+	file, err := buffer.NewBufferFile(rec.Body.Bytes())
+	require.NoError(t, err)
+	pr, err := reader.NewParquetReader(file, new(parquetDates), 1)
+	require.NoError(t, err)
+	defer pr.ReadStop()
+	rows := make([]parquetDates, pr.GetNumRows())
+	require.NoError(t, pr.Read(&rows))
+	require.Equal(t, 3, len(rows))
+	require.NotNil(t, rows[0].CreatedAt)
+	assert.Equal(t, time.Date(2015, 6, 30, 17, 58, 0, 0, time.UTC).UnixNano()/1e6, *rows[0].CreatedAt)
+	assert.Nil(t, rows[2].CreatedAt)
+	assert.Nil(t, rows[2].UpdatedAt)
+}
+
+func TestRecipesHandler_ExportRecipes_UnknownFormat(t *testing.T) {
+	e := echo.New()
+	h := handler.NewRecipesHandler(model.NewRecipesModel())
+	err := h.ExportRecipes(e.NewContext(httptest.NewRequest(echo.GET, "/recipes/export?format=xlsx", nil), httptest.NewRecorder()))
+	assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+}
@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+)
+
+const Fields = "fields"
+
+//fieldset is what ?fields= asks for, names are the JSON ones and nested fields are dot separated (e.g. rates.rate).
+//Field without children means the whole value.
+type fieldset map[string]fieldset
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+//withFields applies ?fields= to recipe or recipes of any version (*model.Recipe, []*RecipeV2...),
+//v is returned untouched when the param is missing
+func withFields(c echo.Context, v interface{}) (interface{}, error) {
+	raw := c.QueryParam(Fields)
+	if raw == "" {
+		return v, nil
+	}
+	value := reflect.ValueOf(v)
+	list := value.Kind() == reflect.Slice
+	t := value.Type()
+	if list {
+		t = t.Elem()
+	}
+	fs, err := parseFieldset(raw, t.Elem())
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if !list {
+		return project(value, fs), nil
+	}
+	sparse := make([]sparseObject, 0, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		sparse = append(sparse, project(value.Index(i), fs).(sparseObject))
+	}
+	return sparse, nil
+}
+
+//parseFieldset validates every path against fields of t
+func parseFieldset(raw string, t reflect.Type) (fieldset, error) {
+	fs := fieldset{}
+	for _, path := range strings.Split(raw, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if err := fs.add(strings.Split(path, "."), t, path); err != nil {
+			return nil, err
+		}
+	}
+	if len(fs) == 0 {
+		return nil, errors.New("No fields given")
+	}
+	return fs, nil
+}
+
+func (fs fieldset) add(path []string, t reflect.Type, fullPath string) error {
+	field, ok := jsonField(t, path[0])
+	if !ok {
+		return errors.Errorf("Unknown field: %s", fullPath)
+	}
+	name := JSONName(field)
+	if len(path) == 1 {
+		//asking for the whole value wins over asking for its parts
+		fs[name] = nil
+		return nil
+	}
+	child := objectType(field.Type)
+	if child == nil {
+		return errors.Errorf("Field has no sub-fields: %s", fullPath)
+	}
+	children, exists := fs[name]
+	if exists && children == nil {
+		return nil
+	}
+	if children == nil {
+		children = fieldset{}
+		fs[name] = children
+	}
+	return children.add(path[1:], child, fullPath)
+}
+
+//fieldAliases are names ?fields= takes for fields whose JSON name breaks the snake case convention, v1 AverageRate
+//keeps its name for existing clients. Responses use the JSON name.
+var fieldAliases = map[string]string{
+	"average_rate": "AverageRate",
+}
+
+//jsonField finds exported struct field by its JSON name or its alias
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for _, n := range []string{name, fieldAliases[name]} {
+		for i := 0; i < t.NumField(); i++ {
+			if field := t.Field(i); n != "" && JSONName(field) == n {
+				return field, true
+			}
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+//JSONName is the key encoding/json writes field under, empty for fields JSON skips.
+//OpenAPI spec and ?fields= both name fields with it.
+func JSONName(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		return ""
+	}
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+//objectType returns struct type behind t (or behind its elements), nil when t is serialized as a single value
+func objectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	pt := reflect.PtrTo(t)
+	if pt.Implements(jsonMarshalerType) || pt.Implements(textMarshalerType) {
+		return nil
+	}
+	return t
+}
+
+func project(v reflect.Value, fs fieldset) interface{} {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return project(v.Elem(), fs)
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, project(v.Index(i), fs))
+		}
+		return items
+	}
+	var sparse sparseObject
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := JSONName(t.Field(i))
+		children, ok := fs[name]
+		if name == "" || !ok {
+			continue
+		}
+		var value interface{} = v.Field(i).Interface()
+		if children != nil {
+			value = project(v.Field(i), children)
+		}
+		sparse = append(sparse, sparseMember{name: name, value: value})
+	}
+	return sparse
+}
+
+//sparseObject is a struct cut down to asked fields, members keep struct order.
+//XML element names are the same as JSON ones.
+type sparseObject []sparseMember
+
+type sparseMember struct {
+	name  string
+	value interface{}
+}
+
+func (o sparseObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, member := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(member.name)
+		value, err := json.Marshal(member.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+func (o sparseObject) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, member := range o {
+		if err := e.EncodeElement(member.value, xml.StartElement{Name: xml.Name{Local: member.name}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
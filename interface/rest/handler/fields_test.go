@@ -0,0 +1,86 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gobonoid/svc-recipes/interface/rest/handler"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecipesHandler_Fields(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.LoadFromCSV(strings.NewReader(exportCSV)))
+	h := handler.NewRecipesHandler(recipesModel)
+	e := echo.New()
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(echo.GET, "/recipes?fields=title,id,calories_k_cal,average_rate", nil), rec)
+	require.NoError(t, h.GetRecipesList(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var recipes []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &recipes))
+	require.Equal(t, 2, len(recipes))
+	assert.Equal(t, 4, len(recipes[0]))
+	assert.Equal(t, "Pork Chilli", recipes[0]["title"])
+	assert.Contains(t, recipes[0], "AverageRate", "average_rate is an alias, v1 name is kept")
+	assert.True(t, strings.HasPrefix(rec.Body.String(), `[{"id":1,"title":"Pork Chilli",`), "members keep struct order")
+
+	req := httptest.NewRequest(echo.GET, "/recipes/1?fields=title", nil)
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationXML)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames("recipeID")
+	c.SetParamValues("1")
+	require.NoError(t, h.GetRecipe(c))
+	var recipe struct {
+		XMLName xml.Name `xml:"recipe"`
+		Title   string   `xml:"title"`
+		Slug    string   `xml:"slug"`
+	}
+	require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &recipe))
+	assert.Equal(t, "Pork Chilli", recipe.Title)
+	assert.Empty(t, recipe.Slug)
+}
+
+func TestRecipesHandler_Fields_Batch(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.LoadFromCSV(strings.NewReader(exportCSV)))
+	h := handler.NewRecipesHandler(recipesModel)
+	e := echo.New()
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, h.GetRecipesList(e.NewContext(httptest.NewRequest(echo.GET, "/recipes?ids=1,42&fields=id,title", nil), rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"recipes": [{"id": 1, "title": "Pork Chilli"}], "missing_ids": [42]}`, rec.Body.String())
+
+	req := httptest.NewRequest(echo.GET, "/recipes?ids=1&fields=title", nil)
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationXML)
+	rec = httptest.NewRecorder()
+	require.NoError(t, h.GetRecipesList(e.NewContext(req, rec)))
+	assert.Contains(t, rec.Body.String(), "<batch><recipes><recipe><title>Pork Chilli</title></recipe></recipes>")
+
+	err := h.GetRecipesList(e.NewContext(httptest.NewRequest(echo.GET, "/recipes?ids=1&fields=marketing", nil), httptest.NewRecorder()))
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+	}
+}
+
+func TestRecipesHandler_Fields_Unknown(t *testing.T) {
+	h := handler.NewRecipesHandler(model.NewRecipesModel())
+	e := echo.New()
+	for _, fields := range []string{"title,marketing", "title.length", ","} {
+		c := e.NewContext(httptest.NewRequest(echo.GET, "/recipes?fields="+fields, nil), httptest.NewRecorder())
+		err := h.GetRecipesList(c)
+		if assert.Error(t, err, fields) {
+			assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code, fields)
+		}
+	}
+}
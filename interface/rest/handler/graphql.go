@@ -0,0 +1,868 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/gobonoid/svc-recipes/tracing"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+)
+
+const (
+	//maxQueryDepth counts nested fields, recipe { related { ratings { rate } } } is 4 levels deep
+	maxQueryDepth = 7
+	//maxQueryComplexity is the number of fields a query may resolve at most, see queryCost
+	maxQueryComplexity = 5000
+	//defaultListSize is what queryCost assumes for lists which can't be sized by first or limit
+	defaultListSize = 10
+
+	maxPageSize = 100
+	maxRelated  = 20
+)
+
+//GraphQLRequest is the body of POST /graphql, GET takes the same as query parameters with variables JSON encoded
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+//GraphQLHandler serves the same recipes as /v2 does, Recipe type has RecipeV2 field names
+type GraphQLHandler struct {
+	recipesAggregator model.RecipesAggregator
+	schema            graphql.Schema
+}
+
+func NewGraphQLHandler(recipesAggregator model.RecipesAggregator) (GraphQLHandler, error) {
+	h := GraphQLHandler{recipesAggregator: recipesAggregator}
+	schema, err := h.newSchema()
+	if err != nil {
+		return h, errors.Wrap(err, "invalid graphql schema")
+	}
+	h.schema = schema
+	return h, nil
+}
+
+//Serve answers both GET and POST /graphql. Errors of the query itself are part of 200 response as GraphQL clients
+//expect, only requests which aren't GraphQL at all get a problem.
+func (h GraphQLHandler) Serve(c echo.Context) error {
+	request, err := graphQLRequest(c)
+	if err != nil {
+		return err
+	}
+	//syntax errors are left to graphql.Do, it reports them with locations
+	if document, err := parser.Parse(parser.ParseParams{Source: request.Query}); err == nil {
+		operation := findOperation(document, request.OperationName)
+		if operation != nil && operation.Operation == ast.OperationTypeMutation && c.Request().Method != echo.POST {
+			c.Response().Header().Set(echo.HeaderAllow, echo.POST)
+			return echo.NewHTTPError(http.StatusMethodNotAllowed, "Mutations have to be sent with POST")
+		}
+		if err := h.checkLimits(document, request.Variables); err != nil {
+			return c.JSON(http.StatusOK, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		}
+	}
+	return c.JSON(http.StatusOK, graphql.Do(graphql.Params{
+		Schema:         h.schema,
+		RequestString:  request.Query,
+		VariableValues: request.Variables,
+		OperationName:  request.OperationName,
+		Context:        c.Request().Context(),
+	}))
+}
+
+func graphQLRequest(c echo.Context) (*GraphQLRequest, error) {
+	request := &GraphQLRequest{}
+	if c.Request().Method == echo.GET {
+		request.Query = c.QueryParam("query")
+		request.OperationName = c.QueryParam("operationName")
+		if variables := c.QueryParam("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				return nil, echo.NewHTTPError(http.StatusBadRequest, "Incorrect variables given")
+			}
+		}
+	} else {
+		if !strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+			return nil, echo.ErrUnsupportedMediaType
+		}
+		if err := json.NewDecoder(c.Request().Body).Decode(request); err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "Incorrect GraphQL request given")
+		}
+	}
+	if strings.TrimSpace(request.Query) == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Missing query")
+	}
+	return request, nil
+}
+
+//findOperation picks the operation graphql.Do is going to execute, nil when the name doesn't match any
+func findOperation(document *ast.Document, name string) *ast.OperationDefinition {
+	for _, definition := range document.Definitions {
+		if operation, ok := definition.(*ast.OperationDefinition); ok {
+			if name == "" || (operation.Name != nil && operation.Name.Value == name) {
+				return operation
+			}
+		}
+	}
+	return nil
+}
+
+//checkLimits measures every operation of the document, not only the executed one
+func (h GraphQLHandler) checkLimits(document *ast.Document, variables map[string]interface{}) error {
+	cost := &queryCost{
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: variables,
+		visiting:  make(map[string]bool),
+	}
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			cost.fragments[fragment.Name.Value] = fragment
+		}
+	}
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		root := h.schema.QueryType()
+		if operation.Operation == ast.OperationTypeMutation {
+			root = h.schema.MutationType()
+		}
+		if root == nil {
+			continue
+		}
+		depth, complexity := cost.selectionSet(root, operation.SelectionSet, false)
+		if cost.err != nil {
+			return cost.err
+		}
+		if depth > maxQueryDepth {
+			return errors.Errorf("Query is %d levels deep, at most %d allowed", depth, maxQueryDepth)
+		}
+		if complexity > maxQueryComplexity {
+			return errors.Errorf("Query complexity is %d, at most %d allowed", complexity, maxQueryComplexity)
+		}
+	}
+	return nil
+}
+
+//queryCost walks selections the way the executor does, fragments included. Every field costs 1 and fields below a list
+//cost as many times as the list is long - first or limit argument when the field has one, defaultListSize otherwise.
+//Size argument of a field which isn't a list itself (recipes connection) sizes the lists right below it.
+//Introspection is left out, it's bounded by the schema. Negative sizes would let one field cancel out cost of its
+//siblings, so they are counted as 0 and reported in err.
+type queryCost struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	visiting  map[string]bool
+	err       error
+}
+
+func (q *queryCost) selectionSet(parent *graphql.Object, set *ast.SelectionSet, sized bool) (depth int, complexity int) {
+	if set == nil {
+		return 0, 0
+	}
+	for _, selection := range set.Selections {
+		var d, c int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			d, c = q.field(parent, selection, sized)
+		case *ast.InlineFragment:
+			d, c = q.selectionSet(parent, selection.SelectionSet, sized)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := q.fragments[name]
+			//cycles are reported by graphql.Do validation
+			if !ok || q.visiting[name] {
+				continue
+			}
+			q.visiting[name] = true
+			d, c = q.selectionSet(parent, fragment.SelectionSet, sized)
+			delete(q.visiting, name)
+		}
+		if d > depth {
+			depth = d
+		}
+		complexity += c
+	}
+	return depth, complexity
+}
+
+func (q *queryCost) field(parent *graphql.Object, field *ast.Field, sized bool) (int, int) {
+	if strings.HasPrefix(field.Name.Value, "__") {
+		return 0, 0
+	}
+	definition, ok := parent.Fields()[field.Name.Value]
+	if !ok || field.SelectionSet == nil {
+		return 1, 1
+	}
+	object, list := objectOf(definition.Type)
+	if object == nil {
+		return 1, 1
+	}
+	size, hasSize := q.size(definition, field)
+	multiplier := 1
+	if hasSize && size < 0 {
+		if q.err == nil {
+			q.err = errors.Errorf("%s can't be sized by negative %d", field.Name.Value, size)
+		}
+		multiplier = 0
+	} else if hasSize {
+		multiplier = size
+	} else if list && !sized {
+		multiplier = defaultListSize
+	}
+	depth, complexity := q.selectionSet(object, field.SelectionSet, hasSize && !list)
+	return depth + 1, 1 + multiplier*complexity
+}
+
+//size is first or limit argument as given, default of the argument when it's missing
+func (q *queryCost) size(definition *graphql.FieldDefinition, field *ast.Field) (int, bool) {
+	for _, argument := range definition.Args {
+		if argument.Name() != "first" && argument.Name() != "limit" {
+			continue
+		}
+		for _, given := range field.Arguments {
+			if given.Name.Value != argument.Name() {
+				continue
+			}
+			switch value := given.Value.(type) {
+			case *ast.IntValue:
+				if size, err := strconv.Atoi(value.Value); err == nil {
+					return size, true
+				}
+			case *ast.Variable:
+				switch size := q.variables[value.Name.Value].(type) {
+				case float64:
+					return int(size), true
+				case int:
+					return size, true
+				}
+			}
+		}
+		if size, ok := argument.DefaultValue.(int); ok {
+			return size, true
+		}
+	}
+	return 0, false
+}
+
+//objectOf unwraps non null and list types, object is nil for scalars and enums
+func objectOf(t graphql.Type) (object *graphql.Object, list bool) {
+	for {
+		switch wrapped := t.(type) {
+		case *graphql.NonNull:
+			t = wrapped.OfType
+		case *graphql.List:
+			list = true
+			t = wrapped.OfType
+		case *graphql.Object:
+			return wrapped, list
+		default:
+			return nil, list
+		}
+	}
+}
+
+//graphQLError carries problem type and status of the error, the same the REST API would respond with
+type graphQLError struct {
+	message string
+	problem *Problem
+}
+
+func (e *graphQLError) Error() string {
+	return e.message
+}
+
+func (e *graphQLError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"type": e.problem.Type, "status": e.problem.Status}
+	if len(e.problem.Violations) > 0 {
+		extensions["violations"] = e.problem.Violations
+	}
+	return extensions
+}
+
+//resolveError hides details of unexpected errors the same way HTTPErrorHandler does
+func resolveError(err error) error {
+	problem := newProblem(err)
+	message := problem.Detail
+	if message == "" {
+		message = problem.Title
+	}
+	return &graphQLError{message: message, problem: problem}
+}
+
+var dateTimeScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "DateTime",
+	Description: "RFC 3339 date, null when not set. Dates in recipe-data.csv layout are accepted as well.",
+	Serialize: func(value interface{}) interface{} {
+		if date, ok := value.(model.DateTime); ok && !date.IsZero() {
+			return date.UTC().Format(time.RFC3339)
+		}
+		return nil
+	},
+	ParseValue: func(value interface{}) interface{} {
+		if s, ok := value.(string); ok {
+			return parseDateTime(s)
+		}
+		return nil
+	},
+	ParseLiteral: func(value ast.Value) interface{} {
+		if s, ok := value.(*ast.StringValue); ok {
+			return parseDateTime(s.Value)
+		}
+		return nil
+	},
+})
+
+//parseDateTime returns nil for invalid dates, graphql reports them as invalid values
+func parseDateTime(s string) interface{} {
+	var date model.DateTime
+	if err := date.Unmarshal(s); err != nil {
+		return nil
+	}
+	return date
+}
+
+//facetFields are recipe fields recipes can be filtered by and counted in facets, keyed by their RecipeV2 names
+var facetFields = map[string]func(*model.Recipe) string{
+	"box_type":       func(r *model.Recipe) string { return r.BoxType },
+	"cuisine":        func(r *model.Recipe) string { return r.RecipeCuisine },
+	"diet_type":      func(r *model.Recipe) string { return r.RecipeDietTypeId },
+	"protein_source": func(r *model.Recipe) string { return r.ProteinSource },
+	"season":         func(r *model.Recipe) string { return r.Season },
+}
+
+//recipeOrder holds less functions of RecipeSortField values
+var recipeOrder = map[string]func(a, b *model.Recipe) bool{
+	"ID":               func(a, b *model.Recipe) bool { return a.Id < b.Id },
+	"TITLE":            func(a, b *model.Recipe) bool { return strings.ToLower(a.Title) < strings.ToLower(b.Title) },
+	"CREATED_AT":       func(a, b *model.Recipe) bool { return a.CreatedAt.Before(b.CreatedAt.Time) },
+	"PREPARATION_TIME": func(a, b *model.Recipe) bool { return a.PreparationTimeMinutes < b.PreparationTimeMinutes },
+	"CALORIES":         func(a, b *model.Recipe) bool { return a.CaloriesKCal < b.CaloriesKCal },
+	"AVERAGE_RATE":     func(a, b *model.Recipe) bool { return a.AverageRate < b.AverageRate },
+}
+
+type facetValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type rating struct {
+	Rate    int            `json:"rate"`
+	RatedAt model.DateTime `json:"rated_at"`
+	RatedBy string         `json:"rated_by"`
+}
+
+func (h GraphQLHandler) newSchema() (graphql.Schema, error) {
+	nutritionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Nutrition",
+		Fields: graphql.Fields{
+			"calories_kcal": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"protein_grams": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"fat_grams":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"carbs_grams":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+	ratingType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Rating",
+		Fields: graphql.Fields{
+			"rate":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"rated_at": &graphql.Field{Type: dateTimeScalar},
+			"rated_by": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+	ratingStatsType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "RatingStats",
+		Description: "Average, min and max are null until recipe is rated",
+		Fields: graphql.Fields{
+			"count":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"average": &graphql.Field{Type: graphql.Float},
+			"min":     &graphql.Field{Type: graphql.Int},
+			"max":     &graphql.Field{Type: graphql.Int},
+		},
+	})
+	stringList := graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))
+
+	var recipeType *graphql.Object
+	recipeType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Recipe",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":                       &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"created_at":               &graphql.Field{Type: dateTimeScalar},
+				"updated_at":               &graphql.Field{Type: dateTimeScalar},
+				"box_type":                 &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"title":                    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"slug":                     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"short_title":              &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"marketing_description":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"nutrition":                &graphql.Field{Type: graphql.NewNonNull(nutritionType)},
+				"bulletpoints":             &graphql.Field{Type: stringList},
+				"diet_type":                &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"season":                   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"base":                     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"protein_source":           &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"preparation_time_minutes": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"shelf_life_days":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"equipment_needed":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"origin_country":           &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"cuisine":                  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"ingredients":              &graphql.Field{Type: stringList},
+				"gousto_reference":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"average_rate":             &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+				"ratings": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(ratingType))),
+					Description: "Latest given first",
+					Args: graphql.FieldConfigArgument{
+						"first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultListSize},
+						"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+					},
+					Resolve: h.resolveRatings,
+				},
+				"rating_stats": &graphql.Field{
+					Type:    graphql.NewNonNull(ratingStatsType),
+					Resolve: h.resolveRatingStats,
+				},
+				"related": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(recipeType))),
+					Description: "Recipes sharing cuisine or protein source, the ones sharing both and better rated first",
+					Args: graphql.FieldConfigArgument{
+						"limit": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 5},
+					},
+					Resolve: h.resolveRelated,
+				},
+			}
+		}),
+	})
+
+	recipeListType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "RecipeList",
+		Fields: graphql.Fields{
+			"total_count": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"items":       &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(recipeType)))},
+		},
+	})
+	facetValuesType := graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.NewObject(graphql.ObjectConfig{
+		Name: "FacetValue",
+		Fields: graphql.Fields{
+			"value": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"count": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	}))))
+	facetsFields := graphql.Fields{}
+	filterFields := graphql.InputObjectConfigFieldMap{
+		"gousto_reference":             &graphql.InputObjectFieldConfig{Type: graphql.Int},
+		"max_preparation_time_minutes": &graphql.InputObjectFieldConfig{Type: graphql.Int},
+		"min_average_rate":             &graphql.InputObjectFieldConfig{Type: graphql.Float},
+		"title_contains":               &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Case insensitive"},
+	}
+	for name := range facetFields {
+		facetsFields[name] = &graphql.Field{Type: facetValuesType}
+		filterFields[name] = &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Case insensitive"}
+	}
+	facetsType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Facets",
+		Description: "Number of recipes per value, most common first. Recipes without the value aren't counted.",
+		Fields:      facetsFields,
+	})
+	filterType := graphql.NewInputObject(graphql.InputObjectConfig{Name: "RecipeFilter", Fields: filterFields})
+	sortFields := graphql.EnumValueConfigMap{}
+	for name := range recipeOrder {
+		sortFields[name] = &graphql.EnumValueConfig{Value: name}
+	}
+	sortType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "RecipeSort",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"field": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.NewEnum(graphql.EnumConfig{Name: "RecipeSortField", Values: sortFields})),
+			},
+			"order": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewEnum(graphql.EnumConfig{Name: "SortOrder", Values: graphql.EnumValueConfigMap{
+					"ASC":  &graphql.EnumValueConfig{Value: "ASC"},
+					"DESC": &graphql.EnumValueConfig{Value: "DESC"},
+				}}),
+				DefaultValue: "ASC",
+			},
+		},
+	})
+
+	inputStringList := graphql.NewList(graphql.NewNonNull(graphql.String))
+	recipeInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "RecipeInput",
+		Description: "Recipe without id and ratings, it's validated the same way /v2/recipes body is",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"created_at":            &graphql.InputObjectFieldConfig{Type: dateTimeScalar},
+			"updated_at":            &graphql.InputObjectFieldConfig{Type: dateTimeScalar},
+			"box_type":              &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"title":                 &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"slug":                  &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"short_title":           &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"marketing_description": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"nutrition": &graphql.InputObjectFieldConfig{Type: graphql.NewInputObject(graphql.InputObjectConfig{
+				Name: "NutritionInput",
+				Fields: graphql.InputObjectConfigFieldMap{
+					"calories_kcal": &graphql.InputObjectFieldConfig{Type: graphql.Int},
+					"protein_grams": &graphql.InputObjectFieldConfig{Type: graphql.Int},
+					"fat_grams":     &graphql.InputObjectFieldConfig{Type: graphql.Int},
+					"carbs_grams":   &graphql.InputObjectFieldConfig{Type: graphql.Int},
+				},
+			})},
+			"bulletpoints":             &graphql.InputObjectFieldConfig{Type: inputStringList},
+			"diet_type":                &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"season":                   &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"base":                     &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"protein_source":           &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"preparation_time_minutes": &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"shelf_life_days":          &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"equipment_needed":         &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"origin_country":           &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"cuisine":                  &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"ingredients":              &graphql.InputObjectFieldConfig{Type: inputStringList},
+			"gousto_reference":         &graphql.InputObjectFieldConfig{Type: graphql.Int},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"recipe": &graphql.Field{
+				Type:        recipeType,
+				Description: "Recipe by id or by current or old slug, null when there's none",
+				Args: graphql.FieldConfigArgument{
+					"id":   &graphql.ArgumentConfig{Type: graphql.Int},
+					"slug": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: h.resolveRecipe,
+			},
+			"recipes": &graphql.Field{
+				Type: graphql.NewNonNull(recipeListType),
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: filterType},
+					"sort":   &graphql.ArgumentConfig{Type: sortType, Description: "By id when missing"},
+					"first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultListSize},
+					"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: h.resolveRecipes,
+			},
+			"facets": &graphql.Field{
+				Type: graphql.NewNonNull(facetsType),
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: filterType},
+				},
+				Resolve: h.resolveFacets,
+			},
+		},
+	})
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"create_recipe": &graphql.Field{
+				Type: graphql.NewNonNull(recipeType),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(recipeInputType)},
+				},
+				Resolve: h.resolveCreateRecipe,
+			},
+			"update_recipe": &graphql.Field{
+				Type: graphql.NewNonNull(recipeType),
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(recipeInputType)},
+				},
+				Resolve: h.resolveUpdateRecipe,
+			},
+			"rate_recipe": &graphql.Field{
+				Type: graphql.NewNonNull(recipeType),
+				Args: graphql.FieldConfigArgument{
+					"id":       &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"rate":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"rated_by": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: h.resolveRateRecipe,
+			},
+		},
+	})
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+//aggregator traces model calls as part of the request, graphql.Do gets request context
+func (h GraphQLHandler) aggregator(p graphql.ResolveParams) model.RecipesAggregator {
+	return tracing.Model(p.Context, h.recipesAggregator)
+}
+
+func (h GraphQLHandler) resolveRecipe(p graphql.ResolveParams) (interface{}, error) {
+	var recipe *model.Recipe
+	var err error
+	if id, ok := p.Args["id"].(int); ok {
+		recipe, err = h.aggregator(p).FetchOneByID(id)
+	} else if slug, ok := p.Args["slug"].(string); ok {
+		recipe, err = h.aggregator(p).FetchOneBySlug(slug)
+	} else {
+		return nil, errors.New("Either id or slug has to be given")
+	}
+	if err == model.NotFoundError {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	return recipeToV2(recipe), nil
+}
+
+func (h GraphQLHandler) resolveRecipes(p graphql.ResolveParams) (interface{}, error) {
+	first, offset, err := pageArgs(p.Args, maxPageSize)
+	if err != nil {
+		return nil, err
+	}
+	recipes := h.filterRecipes(p)
+	if sorting, ok := p.Args["sort"].(map[string]interface{}); ok {
+		less := recipeOrder[fmt.Sprint(sorting["field"])]
+		descending := sorting["order"] == "DESC"
+		sort.SliceStable(recipes, func(i, j int) bool {
+			if descending {
+				return less(recipes[j], recipes[i])
+			}
+			return less(recipes[i], recipes[j])
+		})
+	}
+	total := len(recipes)
+	if offset > total {
+		offset = total
+	}
+	if first > total-offset {
+		first = total - offset
+	}
+	return map[string]interface{}{
+		"total_count": total,
+		"items":       recipesToV2(recipes[offset : offset+first]),
+	}, nil
+}
+
+func (h GraphQLHandler) resolveFacets(p graphql.ResolveParams) (interface{}, error) {
+	recipes := h.filterRecipes(p)
+	facets := make(map[string]interface{})
+	for name, field := range facetFields {
+		counts := make(map[string]int)
+		for _, recipe := range recipes {
+			if value := field(recipe); value != "" {
+				counts[value]++
+			}
+		}
+		values := make([]facetValue, 0, len(counts))
+		for value, count := range counts {
+			values = append(values, facetValue{Value: value, Count: count})
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if values[i].Count != values[j].Count {
+				return values[i].Count > values[j].Count
+			}
+			return values[i].Value < values[j].Value
+		})
+		facets[name] = values
+	}
+	return facets, nil
+}
+
+//filterRecipes returns recipes matching RecipeFilter of filter argument ordered by id, fields given as null don't filter
+func (h GraphQLHandler) filterRecipes(p graphql.ResolveParams) []*model.Recipe {
+	filter := p.Args["filter"]
+	recipes := h.aggregator(p).FetchRecipes(&model.Limiter{Page: 1})
+	conditions, _ := filter.(map[string]interface{})
+	if len(conditions) == 0 {
+		return recipes
+	}
+	matching := make([]*model.Recipe, 0, len(recipes))
+	for _, recipe := range recipes {
+		if matchesFilter(recipe, conditions) {
+			matching = append(matching, recipe)
+		}
+	}
+	return matching
+}
+
+func matchesFilter(recipe *model.Recipe, conditions map[string]interface{}) bool {
+	for name, condition := range conditions {
+		switch value := condition.(type) {
+		case int:
+			if name == "gousto_reference" && recipe.GoustoReference != value {
+				return false
+			}
+			if name == "max_preparation_time_minutes" && recipe.PreparationTimeMinutes > value {
+				return false
+			}
+		case float64:
+			if name == "min_average_rate" && float64(recipe.AverageRate) < value {
+				return false
+			}
+		case string:
+			if name == "title_contains" {
+				if !strings.Contains(strings.ToLower(recipe.Title), strings.ToLower(value)) {
+					return false
+				}
+			} else if field, ok := facetFields[name]; ok && !strings.EqualFold(field(recipe), value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (h GraphQLHandler) resolveRatings(p graphql.ResolveParams) (interface{}, error) {
+	first, offset, err := pageArgs(p.Args, maxPageSize)
+	if err != nil {
+		return nil, err
+	}
+	rates, err := h.aggregator(p).FetchRates(p.Source.(*RecipeV2).ID)
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	ratings := []rating{}
+	for i := len(rates) - 1 - offset; i >= 0 && len(ratings) < first; i-- {
+		ratings = append(ratings, rating{Rate: rates[i].Rate, RatedAt: rates[i].RatedAt, RatedBy: rates[i].RatedBy})
+	}
+	return ratings, nil
+}
+
+func (h GraphQLHandler) resolveRatingStats(p graphql.ResolveParams) (interface{}, error) {
+	rates, err := h.aggregator(p).FetchRates(p.Source.(*RecipeV2).ID)
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	stats := map[string]interface{}{"count": len(rates)}
+	if len(rates) == 0 {
+		return stats, nil
+	}
+	sum, min, max := 0, rates[0].Rate, rates[0].Rate
+	for _, rate := range rates {
+		sum += rate.Rate
+		if rate.Rate < min {
+			min = rate.Rate
+		}
+		if rate.Rate > max {
+			max = rate.Rate
+		}
+	}
+	stats["average"] = float64(sum) / float64(len(rates))
+	stats["min"] = min
+	stats["max"] = max
+	return stats, nil
+}
+
+func (h GraphQLHandler) resolveRelated(p graphql.ResolveParams) (interface{}, error) {
+	limit, _ := p.Args["limit"].(int)
+	if limit < 0 || limit > maxRelated {
+		return nil, errors.Errorf("limit has to be between 0 and %d", maxRelated)
+	}
+	source := p.Source.(*RecipeV2)
+	type candidate struct {
+		recipe *model.Recipe
+		score  int
+	}
+	var candidates []candidate
+	for _, recipe := range h.aggregator(p).FetchRecipes(&model.Limiter{Page: 1}) {
+		if recipe.Id == source.ID {
+			continue
+		}
+		score := 0
+		if source.Cuisine != "" && strings.EqualFold(recipe.RecipeCuisine, source.Cuisine) {
+			score++
+		}
+		if source.ProteinSource != "" && strings.EqualFold(recipe.ProteinSource, source.ProteinSource) {
+			score++
+		}
+		if score > 0 {
+			candidates = append(candidates, candidate{recipe: recipe, score: score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].recipe.AverageRate > candidates[j].recipe.AverageRate
+	})
+	related := make([]*RecipeV2, 0, limit)
+	for i := 0; i < len(candidates) && i < limit; i++ {
+		related = append(related, recipeToV2(candidates[i].recipe))
+	}
+	return related, nil
+}
+
+func (h GraphQLHandler) resolveCreateRecipe(p graphql.ResolveParams) (interface{}, error) {
+	recipe, err := recipeFromInput(p.Args["input"])
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	recipe.Id = time.Now().Nanosecond()
+	if err := h.aggregator(p).CreateRecipe(recipe); err != nil {
+		return nil, resolveError(err)
+	}
+	return recipeToV2(recipe), nil
+}
+
+func (h GraphQLHandler) resolveUpdateRecipe(p graphql.ResolveParams) (interface{}, error) {
+	recipe, err := recipeFromInput(p.Args["input"])
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	recipe.Id = p.Args["id"].(int)
+	if err := h.aggregator(p).UpdateRecipe(recipe.Id, recipe); err != nil {
+		return nil, resolveError(err)
+	}
+	return recipeToV2(recipe), nil
+}
+
+func (h GraphQLHandler) resolveRateRecipe(p graphql.ResolveParams) (interface{}, error) {
+	id := p.Args["id"].(int)
+	ratedBy, _ := p.Args["rated_by"].(string)
+	rate := &model.RecipeRate{Rate: p.Args["rate"].(int), RatedAt: model.DateTime{Time: time.Now()}, RatedBy: ratedBy}
+	if err := h.aggregator(p).RateRecipe(id, rate); err != nil {
+		return nil, resolveError(err)
+	}
+	recipe, err := h.aggregator(p).FetchOneByID(id)
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	return recipeToV2(recipe), nil
+}
+
+//recipeFromInput goes through JSON as RecipeInput fields are named after RecipeV2 ones
+func recipeFromInput(input interface{}) (*model.Recipe, error) {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal recipe input")
+	}
+	v2 := &RecipeV2{}
+	if err := json.Unmarshal(body, v2); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal recipe input")
+	}
+	recipe, err := v2.toModel()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateV2(recipe); err != nil {
+		return nil, err
+	}
+	return recipe, nil
+}
+
+func pageArgs(args map[string]interface{}, maxFirst int) (first int, offset int, err error) {
+	first, _ = args["first"].(int)
+	offset, _ = args["offset"].(int)
+	if first < 0 || first > maxFirst {
+		return 0, 0, errors.Errorf("first has to be between 0 and %d", maxFirst)
+	}
+	if offset < 0 {
+		return 0, 0, errors.New("offset can't be negative")
+	}
+	return first, offset, nil
+}
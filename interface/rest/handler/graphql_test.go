@@ -0,0 +1,164 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gobonoid/svc-recipes/interface/rest/handler"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type graphQLResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func newGraphQLModel(t *testing.T) *model.RecipesModel {
+	recipesModel := model.NewRecipesModel()
+	for _, recipe := range []*model.Recipe{
+		{Id: 1, Title: "Pork Chilli", RecipeCuisine: "mexican", ProteinSource: "pork", PreparationTimeMinutes: 35, GoustoReference: 59},
+		{Id: 2, Title: "Pork Tacos", RecipeCuisine: "mexican", ProteinSource: "pork", PreparationTimeMinutes: 20},
+		{Id: 3, Title: "Beef Burrito", RecipeCuisine: "mexican", ProteinSource: "beef", PreparationTimeMinutes: 40},
+		{Id: 4, Title: "Fish Pie", RecipeCuisine: "british", ProteinSource: "fish", PreparationTimeMinutes: 50},
+	} {
+		require.NoError(t, recipesModel.CreateRecipe(recipe))
+	}
+	require.NoError(t, recipesModel.RateRecipe(1, &model.RecipeRate{Rate: 2, RatedBy: "anna"}))
+	require.NoError(t, recipesModel.RateRecipe(1, &model.RecipeRate{Rate: 5, RatedBy: "bob"}))
+	require.NoError(t, recipesModel.RateRecipe(3, &model.RecipeRate{Rate: 4}))
+	return recipesModel
+}
+
+func graphQL(t *testing.T, recipesModel *model.RecipesModel, query string, variables map[string]interface{}) graphQLResponse {
+	h, err := handler.NewGraphQLHandler(recipesModel)
+	require.NoError(t, err)
+	body, err := json.Marshal(handler.GraphQLRequest{Query: query, Variables: variables})
+	require.NoError(t, err)
+	req := httptest.NewRequest(echo.POST, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	require.NoError(t, h.Serve(echo.New().NewContext(req, rec)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response graphQLResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response
+}
+
+func TestGraphQLHandler_Recipe(t *testing.T) {
+	response := graphQL(t, newGraphQLModel(t), `{
+		recipe(id: 1) {
+			title
+			average_rate
+			ratings(first: 1) { rate rated_by }
+			rating_stats { count average min max }
+			related { id }
+		}
+		missing: recipe(slug: "nope") { id }
+	}`, nil)
+	require.Empty(t, response.Errors)
+	recipe := response.Data["recipe"].(map[string]interface{})
+	assert.Equal(t, "Pork Chilli", recipe["title"])
+	assert.Equal(t, 3.5, recipe["average_rate"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"rate": 5.0, "rated_by": "bob"}}, recipe["ratings"])
+	assert.Equal(t, map[string]interface{}{"count": 2.0, "average": 3.5, "min": 2.0, "max": 5.0}, recipe["rating_stats"])
+	//tacos share both cuisine and protein, burrito is better rated than the pie but the pie isn't related at all
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"id": 2.0},
+		map[string]interface{}{"id": 3.0},
+	}, recipe["related"])
+	assert.Nil(t, response.Data["missing"])
+}
+
+func TestGraphQLHandler_Recipes(t *testing.T) {
+	response := graphQL(t, newGraphQLModel(t), `query ($cuisine: String) {
+		recipes(filter: {cuisine: $cuisine}, sort: {field: PREPARATION_TIME, order: DESC}, first: 2, offset: 1) {
+			total_count
+			items { id }
+		}
+		facets(filter: {max_preparation_time_minutes: 40}) { cuisine { value count } protein_source { value count } }
+	}`, map[string]interface{}{"cuisine": "Mexican"})
+	require.Empty(t, response.Errors)
+	recipes := response.Data["recipes"].(map[string]interface{})
+	assert.Equal(t, 3.0, recipes["total_count"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"id": 1.0},
+		map[string]interface{}{"id": 2.0},
+	}, recipes["items"])
+	facets := response.Data["facets"].(map[string]interface{})
+	assert.Equal(t, []interface{}{map[string]interface{}{"value": "mexican", "count": 3.0}}, facets["cuisine"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"value": "pork", "count": 2.0},
+		map[string]interface{}{"value": "beef", "count": 1.0},
+	}, facets["protein_source"])
+}
+
+func TestGraphQLHandler_Mutations(t *testing.T) {
+	recipesModel := newGraphQLModel(t)
+	response := graphQL(t, recipesModel, `mutation {
+		update_recipe(id: 4, input: {title: "Fish Pie", cuisine: "british", nutrition: {fat_grams: 12}, ingredients: ["cod", "leek"]}) {
+			nutrition { fat_grams }
+			ingredients
+		}
+		rate_recipe(id: 4, rate: 4, rated_by: "anna") { average_rate }
+	}`, nil)
+	require.Empty(t, response.Errors)
+	assert.Equal(t, map[string]interface{}{"fat_grams": 12.0}, response.Data["update_recipe"].(map[string]interface{})["nutrition"])
+	assert.Equal(t, 4.0, response.Data["rate_recipe"].(map[string]interface{})["average_rate"])
+	recipe, err := recipesModel.FetchOneByID(4)
+	require.NoError(t, err)
+	assert.Equal(t, "cod, leek", recipe.InYourBox)
+
+	response = graphQL(t, recipesModel, `mutation { create_recipe(input: {title: "Lamb Curry", cuisine: "indian"}) { id } }`, nil)
+	require.Equal(t, 1, len(response.Errors))
+	assert.Equal(t, "/problems/validation", response.Errors[0].Extensions["type"])
+	violations := response.Errors[0].Extensions["violations"].([]interface{})
+	assert.Equal(t, "cuisine", violations[0].(map[string]interface{})["field"])
+
+	response = graphQL(t, recipesModel, `mutation { rate_recipe(id: 42, rate: 5) { id } }`, nil)
+	require.Equal(t, 1, len(response.Errors))
+	assert.Equal(t, 404.0, response.Errors[0].Extensions["status"])
+}
+
+func TestGraphQLHandler_Limits(t *testing.T) {
+	recipesModel := newGraphQLModel(t)
+	response := graphQL(t, recipesModel, `{ recipe(id: 1) { related { related { related { related { related { related { id } } } } } } } }`, nil)
+	require.Equal(t, 1, len(response.Errors))
+	assert.Contains(t, response.Errors[0].Message, "levels deep")
+	assert.Nil(t, response.Data)
+
+	response = graphQL(t, recipesModel, `query ($first: Int) { recipes(first: $first) { items { ...deep } } }
+		fragment deep on Recipe { related(limit: 20) { id title ratings { rate rated_by } } }`, map[string]interface{}{"first": 100})
+	require.Equal(t, 1, len(response.Errors))
+	assert.Contains(t, response.Errors[0].Message, "complexity")
+
+	response = graphQL(t, recipesModel, `{ recipes(first: 100) { items { id related(limit: 20) { id title } } } }`, nil)
+	assert.Empty(t, response.Errors)
+
+	response = graphQL(t, recipesModel, `{
+		a: recipes(first: 100) { items { related(limit: 20) { id title ratings { rate rated_by } } } }
+		b: recipes(first: -100000) { items { title } }
+	}`, nil)
+	require.Equal(t, 1, len(response.Errors))
+	assert.Contains(t, response.Errors[0].Message, "negative")
+	assert.Nil(t, response.Data)
+}
+
+func TestGraphQLHandler_MutationOverGET(t *testing.T) {
+	h, err := handler.NewGraphQLHandler(newGraphQLModel(t))
+	require.NoError(t, err)
+	query := url.Values{"query": {`mutation { rate_recipe(id: 1, rate: 5) { id } }`}}
+	c := echo.New().NewContext(httptest.NewRequest(echo.GET, "/graphql?"+query.Encode(), nil), httptest.NewRecorder())
+	err = h.Serve(c)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, err.(*echo.HTTPError).Code)
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gobonoid/svc-recipes/health"
+	"github.com/labstack/echo"
+)
+
+type HealthHandler struct {
+	registry *health.Registry
+}
+
+func NewHealthHandler(registry *health.Registry) HealthHandler {
+	return HealthHandler{
+		registry: registry,
+	}
+}
+
+//Live answers as long as the process can serve HTTP at all, it doesn't run any check
+func (h HealthHandler) Live(c echo.Context) error {
+	return c.JSON(http.StatusOK, health.Report{Status: health.StatusOK, Checks: []health.Result{}})
+}
+
+//Ready is 503 when any check fails or the service is draining, the report is the body either way
+func (h HealthHandler) Ready(c echo.Context) error {
+	report := h.registry.Ready(c.Request().Context())
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	return c.JSON(status, report)
+}
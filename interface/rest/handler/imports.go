@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"github.com/gobonoid/svc-recipes/importer"
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+)
+
+const (
+	Semantics = "semantics"
+
+	//DefaultMaxUploadSize is 32MiB, uploads are held in memory until imported
+	DefaultMaxUploadSize = 32 << 20
+
+	MIMETextCSV           = "text/csv"
+	MIMEApplicationNDJSON = "application/x-ndjson"
+)
+
+var importFormats = map[string]importer.Format{
+	MIMETextCSV:              importer.CSV,
+	echo.MIMEApplicationJSON: importer.JSON,
+	MIMEApplicationNDJSON:    importer.NDJSON,
+	"application/ndjson":     importer.NDJSON,
+}
+
+type ImportsHandler struct {
+	importer      *importer.Importer
+	maxUploadSize int64
+}
+
+type ImportsOption func(*ImportsHandler)
+
+//MaxUploadSize replaces DefaultMaxUploadSize, larger uploads are refused with 413
+func MaxUploadSize(bytes int64) ImportsOption {
+	return func(h *ImportsHandler) {
+		h.maxUploadSize = bytes
+	}
+}
+
+func NewImportsHandler(importer *importer.Importer, options ...ImportsOption) ImportsHandler {
+	h := ImportsHandler{
+		importer:      importer,
+		maxUploadSize: DefaultMaxUploadSize,
+	}
+	for _, option := range options {
+		option(&h)
+	}
+	return h
+}
+
+// CreateImport format is taken from Content-Type, ?semantics=upsert replaces existing recipes instead of reporting them
+func (h ImportsHandler) CreateImport(c echo.Context) error {
+	mediaType, _, err := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	format, ok := importFormats[mediaType]
+	if err != nil || !ok {
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, "Upload has to be text/csv, application/json or application/x-ndjson")
+	}
+	semantics := importer.Insert
+	if s := c.QueryParam(Semantics); s != "" {
+		semantics = importer.Semantics(s)
+		if semantics != importer.Insert && semantics != importer.Upsert {
+			return echo.NewHTTPError(http.StatusBadRequest, "Incorrect semantics given")
+		}
+	}
+	//one byte over the limit is enough to tell the upload is too large, the rest isn't read
+	data, err := ioutil.ReadAll(io.LimitReader(c.Request().Body, h.maxUploadSize+1))
+	if err != nil {
+		return errors.Wrap(err, "failed to read upload")
+	}
+	if int64(len(data)) > h.maxUploadSize {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("Upload can't be larger than %d bytes", h.maxUploadSize))
+	}
+
+	job, err := h.importer.Submit(data, format, semantics)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set(echo.HeaderLocation, c.Request().URL.Path+"/"+job.ID)
+	return c.JSON(http.StatusAccepted, job)
+}
+
+func (h ImportsHandler) GetImport(c echo.Context) error {
+	job, err := h.importer.Get(c.Param("importID"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, job)
+}
+
+func (h ImportsHandler) CancelImport(c echo.Context) error {
+	job, err := h.importer.Cancel(c.Param("importID"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusAccepted, job)
+}
@@ -0,0 +1,65 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gobonoid/svc-recipes/importer"
+	"github.com/gobonoid/svc-recipes/interface/rest/handler"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportsHandler_CreateImport(t *testing.T) {
+	i := importer.NewImporter(model.NewRecipesModel(), 1)
+	defer i.Stop()
+	h := handler.NewImportsHandler(i)
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.POST, "/imports?semantics=upsert", strings.NewReader("id,title\n1,a"))
+	req.Header.Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	rec := httptest.NewRecorder()
+	if assert.NoError(t, h.CreateImport(e.NewContext(req, rec))) {
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, "/imports/1", rec.Header().Get(echo.HeaderLocation))
+		assert.Contains(t, rec.Body.String(), `"semantics":"upsert"`)
+	}
+
+	req = httptest.NewRequest(echo.POST, "/imports", strings.NewReader("<recipes/>"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationXML)
+	assert.Equal(t, http.StatusUnsupportedMediaType, h.CreateImport(e.NewContext(req, httptest.NewRecorder())).(*echo.HTTPError).Code)
+
+	req = httptest.NewRequest(echo.POST, "/imports?semantics=merge", strings.NewReader("[]"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	assert.Equal(t, http.StatusBadRequest, h.CreateImport(e.NewContext(req, httptest.NewRecorder())).(*echo.HTTPError).Code)
+}
+
+func TestImportsHandler_CreateImport_TooLarge(t *testing.T) {
+	i := importer.NewImporter(model.NewRecipesModel(), 1)
+	defer i.Stop()
+	h := handler.NewImportsHandler(i, handler.MaxUploadSize(12))
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.POST, "/imports", strings.NewReader("id,title\n1,a"))
+	req.Header.Set(echo.HeaderContentType, handler.MIMETextCSV)
+	assert.NoError(t, h.CreateImport(e.NewContext(req, httptest.NewRecorder())), "exactly at the limit")
+
+	req = httptest.NewRequest(echo.POST, "/imports", strings.NewReader("id,title\n1,ab"))
+	req.Header.Set(echo.HeaderContentType, handler.MIMETextCSV)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, h.CreateImport(e.NewContext(req, httptest.NewRecorder())).(*echo.HTTPError).Code)
+}
+
+func TestImportsHandler_GetImport_NotFound(t *testing.T) {
+	i := importer.NewImporter(model.NewRecipesModel(), 1)
+	defer i.Stop()
+	h := handler.NewImportsHandler(i)
+
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(echo.GET, "/imports/5", nil), httptest.NewRecorder())
+	c.SetParamNames("importID")
+	c.SetParamValues("5")
+	assert.Equal(t, importer.NotFoundError, h.GetImport(c))
+}
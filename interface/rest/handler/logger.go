@@ -0,0 +1,17 @@
+package handler
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/labstack/echo"
+)
+
+//LoggerKey is where server puts request logger, its entries carry trace ids of the request
+const LoggerKey = "logger"
+
+//logger of the request, requests which didn't go through the server log to the standard logger
+func logger(c echo.Context) *logrus.Entry {
+	if entry, ok := c.Get(LoggerKey).(*logrus.Entry); ok {
+		return entry
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
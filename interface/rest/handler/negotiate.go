@@ -0,0 +1,327 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack"
+)
+
+const (
+	MIMEApplicationMsgpack  = "application/msgpack"
+	MIMEApplicationXMsgpack = "application/x-msgpack"
+)
+
+var UnsupportedBodyError = errors.New("Body can't be represented in this media type")
+
+//codec knows how to write and read one media type. Values are *model.Recipe, []*model.Recipe or *model.RecipeRate,
+//responses can be cut down to sparseObject or []sparseObject too.
+type codec interface {
+	contentType() string
+	encode(w io.Writer, v interface{}) error
+	decode(r io.Reader, v interface{}) error
+}
+
+//codecs in order of preference, first one wins when client accepts anything
+var codecs = []struct {
+	mediaTypes []string
+	codec      codec
+}{
+	{[]string{echo.MIMEApplicationJSON}, jsonCodec{}},
+	{[]string{echo.MIMEApplicationXML, "text/xml"}, xmlCodec{}},
+	{[]string{MIMETextCSV}, csvCodec{mapping: model.DefaultColumnMapping()}},
+	{[]string{MIMEApplicationMsgpack, MIMEApplicationXMsgpack}, msgpackCodec{}},
+}
+
+//respond is c.JSON that honours Accept header
+func respond(c echo.Context, status int, v interface{}) error {
+	codec := negotiate(c.Request().Header.Get(echo.HeaderAccept))
+	if codec == nil {
+		return echo.NewHTTPError(http.StatusNotAcceptable, "Supported media types are application/json, application/xml, text/csv and application/msgpack")
+	}
+	var body bytes.Buffer
+	if err := codec.encode(&body, v); err == UnsupportedBodyError {
+		return echo.NewHTTPError(http.StatusNotAcceptable, err.Error())
+	} else if err != nil {
+		return errors.Wrap(err, "failed to encode response")
+	}
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, codec.contentType())
+	res.Header().Add("Vary", echo.HeaderAccept)
+	res.WriteHeader(status)
+	_, err := res.Write(body.Bytes())
+	return err
+}
+
+//bind is c.Bind that honours Content-Type, body without Content-Type is treated as JSON
+func bind(c echo.Context, v interface{}) error {
+	return bindWithMapping(c, v, model.DefaultColumnMapping())
+}
+
+//bindWithMapping is bind reading text/csv bodies with given column mapping
+func bindWithMapping(c echo.Context, v interface{}, mapping model.ColumnMapping) error {
+	req := c.Request()
+	ctype := req.Header.Get(echo.HeaderContentType)
+	mediaType := echo.MIMEApplicationJSON
+	if ctype != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(ctype); err != nil {
+			return echo.NewHTTPError(http.StatusUnsupportedMediaType, "Incorrect Content-Type given")
+		}
+	}
+	codec := codecFor(mediaType)
+	if codec == nil {
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, "Supported media types are application/json, application/xml, text/csv and application/msgpack")
+	}
+	if _, ok := codec.(csvCodec); ok {
+		codec = csvCodec{mapping: mapping}
+	}
+	if err := codec.decode(req.Body, v); err != nil {
+		if err == UnsupportedBodyError {
+			return echo.NewHTTPError(http.StatusUnsupportedMediaType, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, "Incorrect body given: "+err.Error())
+	}
+	return nil
+}
+
+func codecFor(mediaType string) codec {
+	for _, candidate := range codecs {
+		for _, t := range candidate.mediaTypes {
+			if t == mediaType {
+				return candidate.codec
+			}
+		}
+	}
+	return nil
+}
+
+//negotiate picks codec for Accept header taking q values into account, nil means 406
+func negotiate(accept string) codec {
+	if strings.TrimSpace(accept) == "" {
+		return codecs[0].codec
+	}
+	type acceptedType struct {
+		mediaType string
+		q         float64
+	}
+	var accepted []acceptedType
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			accepted = append(accepted, acceptedType{mediaType, q})
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].q > accepted[j].q
+	})
+	for _, a := range accepted {
+		switch {
+		case a.mediaType == "*/*", a.mediaType == "application/*":
+			return codecs[0].codec
+		case a.mediaType == "text/*":
+			return codecFor(MIMETextCSV)
+		}
+		if codec := codecFor(a.mediaType); codec != nil {
+			return codec
+		}
+	}
+	return nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) contentType() string {
+	return echo.MIMEApplicationJSONCharsetUTF8
+}
+
+func (jsonCodec) encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (jsonCodec) decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+type xmlCodec struct{}
+
+//xmlRecipe and xmlRecipes give recipes lower case root elements, the same as their fields have
+type xmlRecipe struct {
+	XMLName xml.Name `xml:"recipe"`
+	*model.Recipe
+}
+
+type xmlRecipes struct {
+	XMLName xml.Name        `xml:"recipes"`
+	Recipes []*model.Recipe `xml:"recipe"`
+}
+
+type xmlRecipesV2 struct {
+	XMLName xml.Name    `xml:"recipes"`
+	Recipes []*RecipeV2 `xml:"recipe"`
+}
+
+type xmlSparseRecipes struct {
+	XMLName xml.Name       `xml:"recipes"`
+	Recipes []sparseObject `xml:"recipe"`
+}
+
+func (xmlCodec) contentType() string {
+	return echo.MIMEApplicationXMLCharsetUTF8
+}
+
+func (xmlCodec) encode(w io.Writer, v interface{}) error {
+	switch recipe := v.(type) {
+	case *model.Recipe:
+		v = xmlRecipe{Recipe: recipe}
+	case []*model.Recipe:
+		v = xmlRecipes{Recipes: recipe}
+	case []sparseObject:
+		v = xmlSparseRecipes{Recipes: recipe}
+	case []*RecipeV2:
+		v = xmlRecipesV2{Recipes: recipe}
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	if recipe, ok := v.(sparseObject); ok {
+		return xml.NewEncoder(w).EncodeElement(recipe, xml.StartElement{Name: xml.Name{Local: "recipe"}})
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+func (xmlCodec) decode(r io.Reader, v interface{}) error {
+	if recipe, ok := v.(*model.Recipe); ok {
+		v = &xmlRecipe{Recipe: recipe}
+	}
+	return xml.NewDecoder(r).Decode(v)
+}
+
+//csvCodec uses the same header and date layout as recipe-data.csv, body is header plus one row.
+//Header is read with mapping, so it is the one the model imports.
+//Rates have no CSV shape so they get 415.
+type csvCodec struct {
+	mapping model.ColumnMapping
+}
+
+func (csvCodec) contentType() string {
+	return MIMETextCSV + "; charset=UTF-8"
+}
+
+func (csvCodec) encode(w io.Writer, v interface{}) error {
+	var recipes []*model.Recipe
+	switch v := v.(type) {
+	case *model.Recipe:
+		recipes = []*model.Recipe{v}
+	case []*model.Recipe:
+		recipes = v
+	default:
+		return UnsupportedBodyError
+	}
+	encoder, err := model.NewCSVEncoder(w)
+	if err != nil {
+		return err
+	}
+	for _, recipe := range recipes {
+		if err := encoder.Encode(recipe); err != nil {
+			return err
+		}
+	}
+	return encoder.Flush()
+}
+
+func (codec csvCodec) decode(r io.Reader, v interface{}) error {
+	recipe, ok := v.(*model.Recipe)
+	if !ok {
+		return UnsupportedBodyError
+	}
+	decoder, _, err := model.NewCSVDecoderWithMapping(r, codec.mapping)
+	if err != nil {
+		return err
+	}
+	decoded, rowErrors, err := decoder.Next()
+	if err == io.EOF {
+		return errors.New("csv has no rows")
+	}
+	if err != nil {
+		return err
+	}
+	if len(rowErrors) > 0 {
+		return rowErrors[0]
+	}
+	*recipe = *decoded
+	return nil
+}
+
+//msgpackCodec goes through JSON representation, so field names and date format are the same as in JSON
+type msgpackCodec struct{}
+
+func (msgpackCodec) contentType() string {
+	return MIMEApplicationMsgpack
+}
+
+func (msgpackCodec) encode(w io.Writer, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	var generic interface{}
+	if err := d.Decode(&generic); err != nil {
+		return err
+	}
+	return msgpack.NewEncoder(w).Encode(jsonNumbers(generic))
+}
+
+func (msgpackCodec) decode(r io.Reader, v interface{}) error {
+	generic, err := msgpack.NewDecoder(r).DecodeInterface()
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+//jsonNumbers turns json.Number into int64 or float64, otherwise msgpack would encode them as strings
+func jsonNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = jsonNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonNumbers(item)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
@@ -0,0 +1,118 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gobonoid/svc-recipes/interface/rest/handler"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack"
+)
+
+func getRecipe(t *testing.T, h handler.RecipesHandler, accept string) *httptest.ResponseRecorder {
+	e := echo.New()
+	req := httptest.NewRequest(echo.GET, "/recipes/1", nil)
+	req.Header.Set(echo.HeaderAccept, accept)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("recipeID")
+	c.SetParamValues("1")
+	require.NoError(t, h.GetRecipe(c))
+	return rec
+}
+
+func TestRecipesHandler_GetRecipe_Negotiation(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.LoadFromCSV(strings.NewReader(exportCSV)))
+	h := handler.NewRecipesHandler(recipesModel)
+
+	rec := getRecipe(t, h, "")
+	assert.Equal(t, echo.MIMEApplicationJSONCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+
+	rec = getRecipe(t, h, "application/xml;q=0.5, text/csv")
+	assert.True(t, strings.HasPrefix(rec.Header().Get(echo.HeaderContentType), handler.MIMETextCSV))
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "id,created_at,updated_at,"))
+	assert.Contains(t, rec.Body.String(), "1,30/06/2015 17:58:00,01/07/2015 10:00:00,")
+
+	rec = getRecipe(t, h, "application/xml")
+	var recipe struct {
+		XMLName xml.Name `xml:"recipe"`
+		Title   string   `xml:"title"`
+	}
+	require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &recipe))
+	assert.Equal(t, "Pork Chilli", recipe.Title)
+
+	rec = getRecipe(t, h, "application/msgpack")
+	assert.Equal(t, handler.MIMEApplicationMsgpack, rec.Header().Get(echo.HeaderContentType))
+	var generic map[string]interface{}
+	require.NoError(t, msgpack.Unmarshal(rec.Body.Bytes(), &generic))
+	assert.Equal(t, "Pork Chilli", generic["title"])
+	assert.EqualValues(t, 59, generic["gousto_reference"])
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.GET, "/recipes/1", nil)
+	req.Header.Set(echo.HeaderAccept, "image/png")
+	c := e.NewContext(req, httptest.NewRecorder())
+	c.SetParamNames("recipeID")
+	c.SetParamValues("1")
+	assert.Equal(t, http.StatusNotAcceptable, h.GetRecipe(c).(*echo.HTTPError).Code)
+}
+
+func TestRecipesHandler_CreateRecipe_Negotiation(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	h := handler.NewRecipesHandler(recipesModel)
+	e := echo.New()
+
+	body, err := msgpack.Marshal(map[string]interface{}{"title": "Packed", "created_at": "30/06/2015 17:58:00"})
+	require.NoError(t, err)
+	for ctype, body := range map[string]string{
+		handler.MIMEApplicationMsgpack: string(body),
+		echo.MIMEApplicationXML:        `<recipe><title>From XML</title></recipe>`,
+		handler.MIMETextCSV:            "id,title\n0,From CSV",
+	} {
+		req := httptest.NewRequest(echo.POST, "/recipes", bytes.NewReader([]byte(body)))
+		req.Header.Set(echo.HeaderContentType, ctype)
+		rec := httptest.NewRecorder()
+		if assert.NoError(t, h.CreateRecipe(e.NewContext(req, rec)), ctype) {
+			assert.Equal(t, http.StatusCreated, rec.Code)
+		}
+	}
+	assert.Equal(t, 3, len(recipesModel.FetchRecipes(&model.Limiter{})))
+
+	req := httptest.NewRequest(echo.POST, "/recipes", strings.NewReader("title: yaml"))
+	req.Header.Set(echo.HeaderContentType, "application/yaml")
+	assert.Equal(t, http.StatusUnsupportedMediaType, h.CreateRecipe(e.NewContext(req, httptest.NewRecorder())).(*echo.HTTPError).Code)
+
+	req = httptest.NewRequest(echo.POST, "/recipes/1/rates", strings.NewReader("rate\n5"))
+	req.Header.Set(echo.HeaderContentType, handler.MIMETextCSV)
+	c := e.NewContext(req, httptest.NewRecorder())
+	c.SetParamNames("recipeID")
+	c.SetParamValues("1")
+	assert.Equal(t, http.StatusUnsupportedMediaType, h.RateRecipe(c).(*echo.HTTPError).Code)
+}
+
+func TestRecipesHandler_CreateRecipe_CSVColumnMapping(t *testing.T) {
+	recipesModel := model.NewRecipesModel(model.WithColumnMapping(model.ColumnMapping{
+		Aliases:        map[string]string{"name": "title"},
+		Required:       []string{"id", "title"},
+		UnknownColumns: model.RejectUnknownColumns,
+	}))
+	h := handler.NewRecipesHandler(recipesModel)
+	e := echo.New()
+
+	req := httptest.NewRequest(echo.POST, "/recipes", strings.NewReader("id,name\n0,Mapped"))
+	req.Header.Set(echo.HeaderContentType, handler.MIMETextCSV)
+	rec := httptest.NewRecorder()
+	require.NoError(t, h.CreateRecipe(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	recipes := recipesModel.FetchRecipes(&model.Limiter{})
+	require.Equal(t, 1, len(recipes))
+	assert.Equal(t, "Mapped", recipes[0].Title)
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gobonoid/svc-recipes/importer"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/gobonoid/svc-recipes/webhooks"
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+)
+
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+//problemTypeBase prefixes types of problems specific to this service, plain HTTP errors are about:blank
+const problemTypeBase = "/problems/"
+
+//Problem is RFC 7807 error body, Violations is set for invalid recipes only
+type Problem struct {
+	Type       string            `json:"type"`
+	Title      string            `json:"title"`
+	Status     int               `json:"status"`
+	Detail     string            `json:"detail,omitempty"`
+	Instance   string            `json:"instance,omitempty"`
+	RequestID  string            `json:"request_id,omitempty"`
+	Violations []model.Violation `json:"violations,omitempty"`
+}
+
+type problemType struct {
+	status int
+	name   string
+	title  string
+}
+
+//problemTypes maps errors handlers can return as they are to their HTTP representation
+var problemTypes = map[error]problemType{
+	model.NotFoundError:                {http.StatusNotFound, "not-found", "Recipe not found"},
+	model.DuplicateError:               {http.StatusConflict, "duplicate", "Recipe already exists"},
+	model.GoustoReferenceConflictError: {http.StatusConflict, "gousto-reference-conflict", "Gousto reference already used"},
+	model.InvalidImportError:           {http.StatusBadRequest, "invalid-import", "Invalid import"},
+	importer.NotFoundError:             {http.StatusNotFound, "import-not-found", "Import not found"},
+	importer.FinishedError:             {http.StatusConflict, "import-finished", "Import already finished"},
+	importer.QueueFullError:            {http.StatusServiceUnavailable, "import-queue-full", "Too many imports queued"},
+	importer.StoppedError:              {http.StatusServiceUnavailable, "importer-stopped", "Importer stopped"},
+	webhooks.NotFoundError:             {http.StatusNotFound, "webhook-not-found", "Webhook not found"},
+	webhooks.DeadLetterNotFoundError:   {http.StatusNotFound, "dead-letter-not-found", "Dead letter not found"},
+	webhooks.InvalidWebhookError:       {http.StatusUnprocessableEntity, "invalid-webhook", "Invalid webhook"},
+}
+
+//HTTPErrorHandler replaces echo default one, every error leaves the service as application/problem+json.
+//Unexpected errors are logged and their details are not shown to the client.
+func HTTPErrorHandler(err error, c echo.Context) {
+	problem := newProblem(err)
+	problem.Instance = c.Request().URL.Path
+	problem.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+	if problem.RequestID == "" {
+		problem.RequestID = c.Request().Header.Get(echo.HeaderXRequestID)
+	}
+	if problem.Status == http.StatusInternalServerError {
+		logger(c).Errorf("%+v", err)
+	}
+
+	res := c.Response()
+	if res.Committed {
+		return
+	}
+	if c.Request().Method == echo.HEAD {
+		err = c.NoContent(problem.Status)
+	} else {
+		var body []byte
+		if body, err = json.Marshal(problem); err == nil {
+			err = c.Blob(problem.Status, MIMEApplicationProblemJSON, body)
+		}
+	}
+	if err != nil {
+		logger(c).Error(errors.Wrap(err, "failed to send error response"))
+	}
+}
+
+func newProblem(err error) *Problem {
+	cause := errors.Cause(err)
+	if t, ok := problemTypes[cause]; ok {
+		return &Problem{Type: problemTypeBase + t.name, Title: t.title, Status: t.status, Detail: err.Error()}
+	}
+	switch cause := cause.(type) {
+	case *model.ValidationError:
+		return &Problem{
+			Type:       problemTypeBase + "validation",
+			Title:      "Invalid recipe",
+			Status:     http.StatusUnprocessableEntity,
+			Detail:     cause.Error(),
+			Violations: cause.Violations,
+		}
+	case *echo.HTTPError:
+		problem := &Problem{Type: "about:blank", Title: http.StatusText(cause.Code), Status: cause.Code}
+		if detail := fmt.Sprint(cause.Message); detail != problem.Title {
+			problem.Detail = detail
+		}
+		return problem
+	}
+	return &Problem{Type: "about:blank", Title: http.StatusText(http.StatusInternalServerError), Status: http.StatusInternalServerError}
+}
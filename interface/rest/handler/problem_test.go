@@ -0,0 +1,57 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gobonoid/svc-recipes/interface/rest/handler"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func problemFor(t *testing.T, err error) handler.Problem {
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(echo.GET, "/recipes/1", nil), rec)
+	handler.HTTPErrorHandler(err, c)
+	assert.Equal(t, handler.MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+	var problem handler.Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, rec.Code, problem.Status)
+	return problem
+}
+
+func TestHTTPErrorHandler(t *testing.T) {
+	problem := problemFor(t, errors.Wrap(model.DuplicateError, "failed to create"))
+	assert.Equal(t, http.StatusConflict, problem.Status)
+	assert.Equal(t, "/problems/duplicate", problem.Type)
+	assert.Equal(t, "failed to create: Duplicate entry", problem.Detail)
+
+	problem = problemFor(t, (&model.Recipe{}).Validate())
+	assert.Equal(t, http.StatusUnprocessableEntity, problem.Status)
+	assert.Equal(t, []model.Violation{{Field: "title", Rule: "required", Message: "is required"}}, problem.Violations)
+
+	problem = problemFor(t, echo.ErrNotFound)
+	assert.Equal(t, handler.Problem{Type: "about:blank", Title: "Not Found", Status: http.StatusNotFound, Instance: "/recipes/1"}, problem)
+
+	problem = problemFor(t, errors.New("database is on fire"))
+	assert.Equal(t, http.StatusInternalServerError, problem.Status)
+	assert.Empty(t, problem.Detail, "unexpected errors are not shown to clients")
+}
+
+func TestHTTPErrorHandler_RequestLogger(t *testing.T) {
+	var logged bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &logged
+	logger.Formatter = &logrus.JSONFormatter{}
+	c := echo.New().NewContext(httptest.NewRequest(echo.GET, "/recipes/1", nil), httptest.NewRecorder())
+	c.Set(handler.LoggerKey, logger.WithField("trace_id", "4bf92f3577b34da6a3ce929d0e0e4736"))
+	handler.HTTPErrorHandler(errors.New("database is on fire"), c)
+	assert.Contains(t, logged.String(), `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`)
+}
@@ -1,20 +1,42 @@
 package handler
 
 import (
+	"encoding/xml"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gobonoid/svc-recipes/model"
+	"github.com/gobonoid/svc-recipes/tracing"
 	"github.com/labstack/echo"
-	"github.com/pkg/errors"
 )
 
 const (
-	Limit = "limit"
-	Page  = "page"
+	Limit           = "limit"
+	Page            = "page"
+	GoustoReference = "gousto_reference"
+	IDs             = "ids"
+
+	//maxBatchIDs keeps single locked read in the model short
+	maxBatchIDs = 100
 )
 
+//BatchGetResponse is what GET /recipes?ids=1,2,3 returns
+type BatchGetResponse struct {
+	XMLName    xml.Name        `json:"-" xml:"batch"`
+	Recipes    []*model.Recipe `json:"recipes" xml:"recipes>recipe"`
+	MissingIDs []int           `json:"missing_ids" xml:"missing_ids>id"`
+}
+
+//sparseBatchGetResponse is BatchGetResponse with recipes cut down to ?fields=
+type sparseBatchGetResponse struct {
+	XMLName    xml.Name       `json:"-" xml:"batch"`
+	Recipes    []sparseObject `json:"recipes" xml:"recipes>recipe"`
+	MissingIDs []int          `json:"missing_ids" xml:"missing_ids>id"`
+}
+
 type RecipesHandler struct {
 	recipesAggregator model.RecipesAggregator
 }
@@ -25,21 +47,79 @@ func NewRecipesHandler(recipesAggregator model.RecipesAggregator) RecipesHandler
 	}
 }
 
+//aggregator traces model calls as part of the request
+func (h RecipesHandler) aggregator(c echo.Context) model.RecipesAggregator {
+	return tracing.Model(c.Request().Context(), h.recipesAggregator)
+}
+
+//bindRecipe is bind reading csv body with model column mapping
+func (h RecipesHandler) bindRecipe(c echo.Context, recipe *model.Recipe) error {
+	return bindWithMapping(c, recipe, h.aggregator(c).ColumnMapping())
+}
+
 func (h RecipesHandler) CreateRecipe(c echo.Context) error {
 	recipe := &model.Recipe{}
-	if err := c.Bind(recipe); err != nil {
+	if err := h.bindRecipe(c, recipe); err != nil {
+		return err
+	}
+	if err := recipe.Validate(); err != nil {
 		return err
 	}
 	recipe.Id = time.Now().Nanosecond()
-	if err := h.recipesAggregator.CreateRecipe(recipe); err == model.DuplicateError {
-		return echo.NewHTTPError(http.StatusConflict, "Recipe already exists")
+	if err := h.aggregator(c).CreateRecipe(recipe); err != nil {
+		return err
 	}
 	return c.NoContent(http.StatusCreated)
 }
 
 func (h RecipesHandler) GetRecipesList(c echo.Context) error {
-	recipes := h.recipesAggregator.FetchRecipes(recipesListLimiter(c))
-	return c.JSON(http.StatusOK, recipes)
+	if ids := c.QueryParam(IDs); ids != "" {
+		return h.getRecipesBatch(c, ids)
+	}
+	if ref := c.QueryParam(GoustoReference); ref != "" {
+		reference, err := strconv.Atoi(ref)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Incorrect gousto_reference given")
+		}
+		return h.respondWithFields(c, h.aggregator(c).FetchByGoustoReference(reference))
+	}
+	limiter, err := recipesListLimiter(c)
+	if err != nil {
+		return err
+	}
+	return h.respondWithFields(c, h.aggregator(c).FetchRecipes(limiter))
+}
+
+//getRecipesBatch ignores repeated ids, order of the rest is kept
+func (h RecipesHandler) getRecipesBatch(c echo.Context, ids string) error {
+	var recipeIDs []int
+	seen := make(map[int]bool)
+	for _, raw := range strings.Split(ids, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Incorrect ids given")
+		}
+		if !seen[id] {
+			seen[id] = true
+			recipeIDs = append(recipeIDs, id)
+		}
+	}
+	if len(recipeIDs) > maxBatchIDs {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("At most %d ids can be fetched at once", maxBatchIDs))
+	}
+	found, missing := h.aggregator(c).FetchManyByIDs(recipeIDs)
+	if c.QueryParam(Fields) == "" {
+		return respond(c, http.StatusOK, BatchGetResponse{Recipes: found, MissingIDs: missing})
+	}
+	sparse, err := withFields(c, found)
+	if err != nil {
+		return err
+	}
+	return respond(c, http.StatusOK, sparseBatchGetResponse{Recipes: sparse.([]sparseObject), MissingIDs: missing})
+}
+
+func (h RecipesHandler) GetGoustoReferenceConflicts(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.aggregator(c).GoustoReferenceConflicts())
 }
 
 func (h RecipesHandler) GetRecipe(c echo.Context) error {
@@ -47,66 +127,87 @@ func (h RecipesHandler) GetRecipe(c echo.Context) error {
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Incorrect recipeID given")
 	}
-	recipe, err := h.recipesAggregator.FetchOneByID(id)
-	if err == model.NotFoundError {
-		return echo.NewHTTPError(http.StatusNotFound, "Recipe not found")
+	recipe, err := h.aggregator(c).FetchOneByID(id)
+	if err != nil {
+		return err
+	}
+	return h.respondWithFields(c, recipe)
+}
+
+//GetRecipeBySlug redirects permanently when recipe was found by its old slug
+func (h RecipesHandler) GetRecipeBySlug(c echo.Context) error {
+	slug := c.Param("slug")
+	recipe, err := h.aggregator(c).FetchOneBySlug(slug)
+	if err != nil {
+		return err
 	}
-	return c.JSON(http.StatusOK, recipe)
+	if recipe.Slug != slug {
+		location := strings.TrimSuffix(c.Request().URL.Path, slug) + recipe.Slug
+		if query := c.Request().URL.RawQuery; query != "" {
+			location += "?" + query
+		}
+		return c.Redirect(http.StatusMovedPermanently, location)
+	}
+	return h.respondWithFields(c, recipe)
+}
+
+//respondWithFields is 200 response cut down to ?fields= when asked for
+func (h RecipesHandler) respondWithFields(c echo.Context, v interface{}) error {
+	v, err := withFields(c, v)
+	if err != nil {
+		return err
+	}
+	return respond(c, http.StatusOK, v)
 }
 
 func (h RecipesHandler) UpdateRecipe(c echo.Context) error {
 	recipe := &model.Recipe{}
-	if err := c.Bind(recipe); err != nil {
+	if err := h.bindRecipe(c, recipe); err != nil {
 		return err
 	}
 	id, err := strconv.Atoi(c.Param("recipeID"))
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Incorrect recipeID given")
 	}
-
-	if err := h.recipesAggregator.UpdateRecipe(id, recipe); err == model.NotFoundError {
-		return echo.NewHTTPError(http.StatusNotFound, "Recipe not found")
+	if err := recipe.Validate(); err != nil {
+		return err
+	}
+	if err := h.aggregator(c).UpdateRecipe(id, recipe); err != nil {
+		return err
 	}
 	return c.NoContent(http.StatusOK)
 }
 
 func (h RecipesHandler) RateRecipe(c echo.Context) error {
 	recipeRate := &model.RecipeRate{}
-	if err := c.Bind(recipeRate); err != nil {
+	if err := bind(c, recipeRate); err != nil {
 		return err
 	}
 	id, err := strconv.Atoi(c.Param("recipeID"))
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Incorrect recipeID given")
 	}
-	if h.recipesAggregator.RateRecipe(id, recipeRate) == model.NotFoundError {
-		return echo.NewHTTPError(http.StatusNotFound, "Recipe not found")
+	if err := h.aggregator(c).RateRecipe(id, recipeRate); err != nil {
+		return err
 	}
 	return c.NoContent(http.StatusCreated)
 }
 
-func recipesListLimiter(c echo.Context) *model.Limiter {
-	var limit int
-	var page int
+func recipesListLimiter(c echo.Context) (*model.Limiter, error) {
+	limiter := &model.Limiter{Page: 1}
 	if l := c.QueryParam(Limit); l != "" {
-		tmp, err := strconv.Atoi(l)
-		if err != nil {
-			c.Logger().Errorf("%#v", errors.Wrap(err, "Failed to parse given limit"))
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 0 {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "Incorrect limit given")
 		}
-		limit = tmp
+		limiter.Limit = limit
 	}
 	if p := c.QueryParam(Page); p != "" {
-		tmp, err := strconv.Atoi(p)
-		if err != nil {
-			c.Logger().Errorf("%#v", errors.Wrap(err, "Failed to parse given page"))
+		page, err := strconv.Atoi(p)
+		if err != nil || page < 1 {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "Incorrect page given")
 		}
-		page = tmp
-	}
-	if page == 0 {
-		page = 1
-	}
-	return &model.Limiter{
-		Limit: limit,
-		Page:  page,
+		limiter.Page = page
 	}
+	return limiter, nil
 }
@@ -1,6 +1,7 @@
 package handler_test
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -10,12 +11,13 @@ import (
 	"github.com/gobonoid/svc-recipes/model"
 	"github.com/labstack/echo"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRecipesHandler_CreateRecipe(t *testing.T) {
 	// Setup
 	e := echo.New()
-	req := httptest.NewRequest(echo.POST, "/", strings.NewReader(`{"created_at": "30/06/2015 17:58:00"}`))
+	req := httptest.NewRequest(echo.POST, "/", strings.NewReader(`{"title": "Pork Chilli", "created_at": "30/06/2015 17:58:00"}`))
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
 
@@ -44,3 +46,104 @@ func TestRecipesHandler_CreateRecipe_InvalidRecipe(t *testing.T) {
 	assert.Error(t, h.CreateRecipe(c))
 	assert.Equal(t, http.StatusBadRequest, h.CreateRecipe(c).(*echo.HTTPError).Code)
 }
+
+func TestRecipesHandler_GetRecipeBySlug(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Chilli"}))
+	require.NoError(t, recipesModel.UpdateRecipe(1, &model.Recipe{Id: 1, Title: "Spicy Pork Chilli"}))
+	h := handler.NewRecipesHandler(recipesModel)
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.GET, "/recipes/slug/spicy-pork-chilli", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("slug")
+	c.SetParamValues("spicy-pork-chilli")
+	if assert.NoError(t, h.GetRecipeBySlug(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	req = httptest.NewRequest(echo.GET, "/recipes/slug/pork-chilli", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames("slug")
+	c.SetParamValues("pork-chilli")
+	if assert.NoError(t, h.GetRecipeBySlug(c)) {
+		assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+		assert.Equal(t, "/recipes/slug/spicy-pork-chilli", rec.Header().Get(echo.HeaderLocation))
+	}
+}
+
+func TestRecipesHandler_GetRecipesList_GoustoReference(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, GoustoReference: 59}))
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 2, GoustoReference: 60}))
+	h := handler.NewRecipesHandler(recipesModel)
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(echo.GET, "/recipes?gousto_reference=59", nil), rec)
+	if assert.NoError(t, h.GetRecipesList(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"id":1`)
+		assert.NotContains(t, rec.Body.String(), `"id":2`)
+	}
+
+	c = e.NewContext(httptest.NewRequest(echo.GET, "/recipes?gousto_reference=abc", nil), httptest.NewRecorder())
+	assert.Equal(t, http.StatusBadRequest, h.GetRecipesList(c).(*echo.HTTPError).Code)
+}
+
+func TestRecipesHandler_GetRecipesList_PagePastTheEnd(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1}))
+	h := handler.NewRecipesHandler(recipesModel)
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(echo.GET, "/recipes?limit=5&page=10", nil), rec)
+	if assert.NoError(t, h.GetRecipesList(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "[]", strings.TrimSpace(rec.Body.String()))
+	}
+}
+
+func TestRecipesHandler_GetRecipesList_IDs(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1}))
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 2}))
+	h := handler.NewRecipesHandler(recipesModel)
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(echo.GET, "/recipes?ids=2,5,2,1", nil), rec)
+	if assert.NoError(t, h.GetRecipesList(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var response handler.BatchGetResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		require.Equal(t, 2, len(response.Recipes))
+		assert.Equal(t, 2, response.Recipes[0].Id)
+		assert.Equal(t, 1, response.Recipes[1].Id)
+		assert.Equal(t, []int{5}, response.MissingIDs)
+	}
+
+	c = e.NewContext(httptest.NewRequest(echo.GET, "/recipes?ids=1,x", nil), httptest.NewRecorder())
+	assert.Equal(t, http.StatusBadRequest, h.GetRecipesList(c).(*echo.HTTPError).Code)
+}
+
+func TestRecipesHandler_CreateRecipe_Validation(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(echo.POST, "/", strings.NewReader(`{"box_type": "family", "fat_grams": -2}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	h := handler.NewRecipesHandler(model.NewRecipesModel())
+	handler.HTTPErrorHandler(h.CreateRecipe(c), c)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	var response handler.Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Equal(t, 3, len(response.Violations))
+	assert.Equal(t, "box_type", response.Violations[0].Field)
+	assert.Equal(t, "title", response.Violations[1].Field)
+	assert.Equal(t, "fat_grams", response.Violations[2].Field)
+}
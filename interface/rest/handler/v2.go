@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/labstack/echo"
+)
+
+//RecipeV2 is /v2 representation of model.Recipe. Compared to v1 field names follow the CSV ones, nutrition is nested
+//and bulletpoints and ingredients (in_your_box) are arrays. recipeToV2 and RecipeV2.toModel are the only mapping
+//between the two, any new Recipe field has to be added to both.
+type RecipeV2 struct {
+	XMLName                xml.Name       `json:"-" xml:"recipe"`
+	ID                     int            `json:"id" xml:"id"`
+	CreatedAt              model.DateTime `json:"created_at" xml:"created_at"`
+	UpdatedAt              model.DateTime `json:"updated_at" xml:"updated_at"`
+	BoxType                string         `json:"box_type" xml:"box_type"`
+	Title                  string         `json:"title" xml:"title"`
+	Slug                   string         `json:"slug" xml:"slug"`
+	ShortTitle             string         `json:"short_title" xml:"short_title"`
+	MarketingDescription   string         `json:"marketing_description" xml:"marketing_description"`
+	Nutrition              NutritionV2    `json:"nutrition" xml:"nutrition"`
+	Bulletpoints           []string       `json:"bulletpoints" xml:"bulletpoints>bulletpoint"`
+	DietType               string         `json:"diet_type" xml:"diet_type"`
+	Season                 string         `json:"season" xml:"season"`
+	Base                   string         `json:"base" xml:"base"`
+	ProteinSource          string         `json:"protein_source" xml:"protein_source"`
+	PreparationTimeMinutes int            `json:"preparation_time_minutes" xml:"preparation_time_minutes"`
+	ShelfLifeDays          int            `json:"shelf_life_days" xml:"shelf_life_days"`
+	EquipmentNeeded        string         `json:"equipment_needed" xml:"equipment_needed"`
+	OriginCountry          string         `json:"origin_country" xml:"origin_country"`
+	Cuisine                string         `json:"cuisine" xml:"cuisine"`
+	Ingredients            []string       `json:"ingredients" xml:"ingredients>ingredient"`
+	GoustoReference        int            `json:"gousto_reference" xml:"gousto_reference"`
+	AverageRate            float32        `json:"average_rate" xml:"average_rate"`
+}
+
+type NutritionV2 struct {
+	CaloriesKCal int `json:"calories_kcal" xml:"calories_kcal"`
+	ProteinGrams int `json:"protein_grams" xml:"protein_grams"`
+	FatGrams     int `json:"fat_grams" xml:"fat_grams"`
+	CarbsGrams   int `json:"carbs_grams" xml:"carbs_grams"`
+}
+
+//v2FieldNames translates v1 field names in validation violations, names which didn't change are missing
+var v2FieldNames = map[string]string{
+	"uploaded_at":         "updated_at",
+	"calories_k_cal":      "nutrition.calories_kcal",
+	"protein_grams":       "nutrition.protein_grams",
+	"fat_grams":           "nutrition.fat_grams",
+	"carbs_grams":         "nutrition.carbs_grams",
+	"bulletpoint_1":       "bulletpoints",
+	"bulletpoint_2":       "bulletpoints",
+	"bulletpoint_3":       "bulletpoints",
+	"recipe_diet_type_id": "diet_type",
+	"recipe_cuisine":      "cuisine",
+	"in_your_box":         "ingredients",
+}
+
+//recipeToV2 drops empty bulletpoints, so their position isn't kept
+func recipeToV2(recipe *model.Recipe) *RecipeV2 {
+	return &RecipeV2{
+		ID:                   recipe.Id,
+		CreatedAt:            recipe.CreatedAt,
+		UpdatedAt:            recipe.UpdatedAt,
+		BoxType:              recipe.BoxType,
+		Title:                recipe.Title,
+		Slug:                 recipe.Slug,
+		ShortTitle:           recipe.ShortTitle,
+		MarketingDescription: recipe.MarketingDescription,
+		Nutrition: NutritionV2{
+			CaloriesKCal: recipe.CaloriesKCal,
+			ProteinGrams: recipe.ProteinGrams,
+			FatGrams:     recipe.FatGrams,
+			CarbsGrams:   recipe.CarbsGrams,
+		},
+		Bulletpoints:           recipe.Bulletpoints(),
+		DietType:               recipe.RecipeDietTypeId,
+		Season:                 recipe.Season,
+		Base:                   recipe.Base,
+		ProteinSource:          recipe.ProteinSource,
+		PreparationTimeMinutes: recipe.PreparationTimeMinutes,
+		ShelfLifeDays:          recipe.ShelfLifeDays,
+		EquipmentNeeded:        recipe.EquipmentNeeded,
+		OriginCountry:          recipe.OriginCountry,
+		Cuisine:                recipe.RecipeCuisine,
+		Ingredients:            recipe.Ingredients(),
+		GoustoReference:        recipe.GoustoReference,
+		AverageRate:            recipe.AverageRate,
+	}
+}
+
+func recipesToV2(recipes []*model.Recipe) []*RecipeV2 {
+	v2 := make([]*RecipeV2, 0, len(recipes))
+	for _, recipe := range recipes {
+		v2 = append(v2, recipeToV2(recipe))
+	}
+	return v2
+}
+
+//toModel validates what v1 can't express, AverageRate is read only
+func (r *RecipeV2) toModel() (*model.Recipe, error) {
+	recipe := &model.Recipe{
+		Id:                     r.ID,
+		CreatedAt:              r.CreatedAt,
+		UpdatedAt:              r.UpdatedAt,
+		BoxType:                r.BoxType,
+		Title:                  r.Title,
+		Slug:                   r.Slug,
+		ShortTitle:             r.ShortTitle,
+		MarketingDescription:   r.MarketingDescription,
+		CaloriesKCal:           r.Nutrition.CaloriesKCal,
+		ProteinGrams:           r.Nutrition.ProteinGrams,
+		FatGrams:               r.Nutrition.FatGrams,
+		CarbsGrams:             r.Nutrition.CarbsGrams,
+		RecipeDietTypeId:       r.DietType,
+		Season:                 r.Season,
+		Base:                   r.Base,
+		ProteinSource:          r.ProteinSource,
+		PreparationTimeMinutes: r.PreparationTimeMinutes,
+		ShelfLifeDays:          r.ShelfLifeDays,
+		EquipmentNeeded:        r.EquipmentNeeded,
+		OriginCountry:          r.OriginCountry,
+		RecipeCuisine:          r.Cuisine,
+		GoustoReference:        r.GoustoReference,
+	}
+	if err := recipe.SetBulletpoints(r.Bulletpoints); err != nil {
+		return nil, err
+	}
+	recipe.SetIngredients(r.Ingredients)
+	return recipe, nil
+}
+
+//validateV2 is Recipe.Validate with violations named the v2 way
+func validateV2(recipe *model.Recipe) error {
+	err := recipe.Validate()
+	if err == nil {
+		return nil
+	}
+	violations := err.(*model.ValidationError).Violations
+	for i := range violations {
+		if name, ok := v2FieldNames[violations[i].Field]; ok {
+			violations[i].Field = name
+		}
+	}
+	return err
+}
+
+//bindV2 reads RecipeV2 body and turns it into valid model.Recipe
+func bindV2(c echo.Context) (*model.Recipe, error) {
+	v2 := &RecipeV2{}
+	if err := bind(c, v2); err != nil {
+		return nil, err
+	}
+	recipe, err := v2.toModel()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateV2(recipe); err != nil {
+		return nil, err
+	}
+	return recipe, nil
+}
+
+func (h RecipesHandler) CreateRecipeV2(c echo.Context) error {
+	recipe, err := bindV2(c)
+	if err != nil {
+		return err
+	}
+	recipe.Id = time.Now().Nanosecond()
+	if err := h.aggregator(c).CreateRecipe(recipe); err != nil {
+		return err
+	}
+	c.Response().Header().Set(echo.HeaderLocation, c.Request().URL.Path+"/"+strconv.Itoa(recipe.Id))
+	return respond(c, http.StatusCreated, recipeToV2(recipe))
+}
+
+func (h RecipesHandler) GetRecipesListV2(c echo.Context) error {
+	if ref := c.QueryParam(GoustoReference); ref != "" {
+		reference, err := strconv.Atoi(ref)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Incorrect gousto_reference given")
+		}
+		return h.respondWithFields(c, recipesToV2(h.aggregator(c).FetchByGoustoReference(reference)))
+	}
+	limiter, err := recipesListLimiter(c)
+	if err != nil {
+		return err
+	}
+	return h.respondWithFields(c, recipesToV2(h.aggregator(c).FetchRecipes(limiter)))
+}
+
+func (h RecipesHandler) GetRecipeV2(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("recipeID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Incorrect recipeID given")
+	}
+	recipe, err := h.aggregator(c).FetchOneByID(id)
+	if err != nil {
+		return err
+	}
+	return h.respondWithFields(c, recipeToV2(recipe))
+}
+
+//UpdateRecipeV2 takes id from the path only, id in the body is ignored
+func (h RecipesHandler) UpdateRecipeV2(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("recipeID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Incorrect recipeID given")
+	}
+	recipe, err := bindV2(c)
+	if err != nil {
+		return err
+	}
+	recipe.Id = id
+	if err := h.aggregator(c).UpdateRecipe(id, recipe); err != nil {
+		return err
+	}
+	return respond(c, http.StatusOK, recipeToV2(recipe))
+}
@@ -0,0 +1,84 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gobonoid/svc-recipes/interface/rest/handler"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecipesHandler_GetRecipeV2(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{
+		Id:           1,
+		Title:        "Pork Chilli",
+		CaloriesKCal: 401,
+		Bulletpoint1: "Spicy",
+		Bulletpoint3: "Quick",
+		InYourBox:    "pork, chilli,  rice",
+	}))
+	h := handler.NewRecipesHandler(recipesModel)
+
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(echo.GET, "/v2/recipes/1", nil), rec)
+	c.SetParamNames("recipeID")
+	c.SetParamValues("1")
+	require.NoError(t, h.GetRecipeV2(c))
+	var recipe handler.RecipeV2
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &recipe))
+	assert.Equal(t, 401, recipe.Nutrition.CaloriesKCal)
+	assert.Equal(t, []string{"Spicy", "Quick"}, recipe.Bulletpoints)
+	assert.Equal(t, []string{"pork", "chilli", "rice"}, recipe.Ingredients)
+
+	rec = httptest.NewRecorder()
+	c = echo.New().NewContext(httptest.NewRequest(echo.GET, "/v2/recipes/1?fields=title,nutrition.calories_kcal", nil), rec)
+	c.SetParamNames("recipeID")
+	c.SetParamValues("1")
+	require.NoError(t, h.GetRecipeV2(c))
+	assert.JSONEq(t, `{"title": "Pork Chilli", "nutrition": {"calories_kcal": 401}}`, rec.Body.String())
+}
+
+func TestRecipesHandler_UpdateRecipeV2(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Chilli"}))
+	h := handler.NewRecipesHandler(recipesModel)
+
+	update := func(body string) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(echo.PUT, "/v2/recipes/1", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		c.SetParamNames("recipeID")
+		c.SetParamValues("1")
+		return rec, h.UpdateRecipeV2(c)
+	}
+
+	rec, err := update(`{"title": "Beef Chilli", "nutrition": {"protein_grams": 30}, "bulletpoints": ["Hot"], "ingredients": ["beef", "beans"], "cuisine": "mexican"}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	recipe, err := recipesModel.FetchOneByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, "Beef Chilli", recipe.Title)
+	assert.Equal(t, 30, recipe.ProteinGrams)
+	assert.Equal(t, "Hot", recipe.Bulletpoint1)
+	assert.Equal(t, "beef, beans", recipe.InYourBox)
+	assert.Equal(t, "mexican", recipe.RecipeCuisine)
+
+	_, err = update(`{"title": "Beef Chilli", "nutrition": {"fat_grams": -1}, "cuisine": "martian"}`)
+	require.IsType(t, &model.ValidationError{}, err)
+	assert.Equal(t, []model.Violation{
+		{Field: "nutrition.fat_grams", Rule: "min", Message: "has to be at least 0"},
+		{Field: "cuisine", Rule: "oneof", Message: "has to be one of: asian, british, italian, mediterranean, mexican"},
+	}, err.(*model.ValidationError).Violations)
+
+	_, err = update(`{"title": "Beef Chilli", "bulletpoints": ["a", "b", "c", "d"]}`)
+	require.IsType(t, &model.ValidationError{}, err)
+	assert.Equal(t, "bulletpoints", err.(*model.ValidationError).Violations[0].Field)
+}
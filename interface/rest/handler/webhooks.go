@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gobonoid/svc-recipes/webhooks"
+	"github.com/labstack/echo"
+)
+
+type WebhooksHandler struct {
+	dispatcher *webhooks.Dispatcher
+}
+
+func NewWebhooksHandler(dispatcher *webhooks.Dispatcher) WebhooksHandler {
+	return WebhooksHandler{
+		dispatcher: dispatcher,
+	}
+}
+
+//CreateWebhook takes url, events and secret, the secret isn't returned back
+func (h WebhooksHandler) CreateWebhook(c echo.Context) error {
+	if !strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		return echo.ErrUnsupportedMediaType
+	}
+	webhook := webhooks.Webhook{}
+	if err := json.NewDecoder(c.Request().Body).Decode(&webhook); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Incorrect body given: "+err.Error())
+	}
+	webhook, err := h.dispatcher.Register(webhook)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set(echo.HeaderLocation, c.Request().URL.Path+"/"+webhook.ID)
+	return c.JSON(http.StatusCreated, webhook)
+}
+
+func (h WebhooksHandler) GetWebhooks(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.dispatcher.List())
+}
+
+func (h WebhooksHandler) GetWebhook(c echo.Context) error {
+	webhook, err := h.dispatcher.Get(c.Param("webhookID"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, webhook)
+}
+
+func (h WebhooksHandler) DeleteWebhook(c echo.Context) error {
+	if err := h.dispatcher.Delete(c.Param("webhookID")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h WebhooksHandler) GetDeadLetters(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.dispatcher.DeadLetters())
+}
+
+func (h WebhooksHandler) RedeliverDeadLetter(c echo.Context) error {
+	if err := h.dispatcher.Redeliver(c.Param("deliveryID")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusAccepted)
+}
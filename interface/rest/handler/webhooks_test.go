@@ -0,0 +1,52 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gobonoid/svc-recipes/interface/rest/handler"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/gobonoid/svc-recipes/webhooks"
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhooksHandler_CreateWebhook(t *testing.T) {
+	d := webhooks.NewDispatcher(model.NewRecipesModel(), logrus.New())
+	defer d.Stop()
+	h := handler.NewWebhooksHandler(d)
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.POST, "/webhooks", strings.NewReader(`{"url": "https://partner.com/hooks", "events": ["created", "rated"], "secret": "s3cret"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	if assert.NoError(t, h.CreateWebhook(e.NewContext(req, rec))) {
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "/webhooks/1", rec.Header().Get(echo.HeaderLocation))
+		assert.Contains(t, rec.Body.String(), `"events":["created","rated"]`)
+		assert.NotContains(t, rec.Body.String(), "s3cret")
+	}
+
+	req = httptest.NewRequest(echo.POST, "/webhooks", strings.NewReader(`{"url": "https://partner.com/hooks", "events": ["rated"]}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	assert.Equal(t, webhooks.InvalidWebhookError, errors.Cause(h.CreateWebhook(e.NewContext(req, httptest.NewRecorder()))))
+
+	req = httptest.NewRequest(echo.POST, "/webhooks", strings.NewReader("<webhook/>"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationXML)
+	assert.Equal(t, http.StatusUnsupportedMediaType, h.CreateWebhook(e.NewContext(req, httptest.NewRecorder())).(*echo.HTTPError).Code)
+}
+
+func TestWebhooksHandler_DeleteWebhook_NotFound(t *testing.T) {
+	d := webhooks.NewDispatcher(model.NewRecipesModel(), logrus.New())
+	defer d.Stop()
+	h := handler.NewWebhooksHandler(d)
+
+	c := echo.New().NewContext(httptest.NewRequest(echo.DELETE, "/webhooks/5", nil), httptest.NewRecorder())
+	c.SetParamNames("webhookID")
+	c.SetParamValues("5")
+	assert.Equal(t, webhooks.NotFoundError, h.DeleteWebhook(c))
+}
@@ -0,0 +1,597 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/gobonoid/svc-recipes/health"
+	"github.com/gobonoid/svc-recipes/importer"
+	"github.com/gobonoid/svc-recipes/interface/rest/handler"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/gobonoid/svc-recipes/webhooks"
+	"github.com/labstack/echo"
+)
+
+const openAPIPath = "/openapi.json"
+
+//OpenAPI is the subset of OpenAPI 3 document this service needs
+type OpenAPI struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       OpenAPIInfo         `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components OpenAPIComponents   `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+//PathItem maps lower case HTTP method to its operation
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string              `json:"summary"`
+	OperationID string              `json:"operationId"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+	Deprecated  bool                `json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+//enums lists values of typed string constants, reflection can't find them
+var enums = map[reflect.Type][]string{
+	reflect.TypeOf(model.BatchOperationType("")): {string(model.BatchCreate), string(model.BatchUpdate), string(model.BatchDelete)},
+	reflect.TypeOf(model.EventType("")):          {string(model.RecipeCreated), string(model.RecipeUpdated), string(model.RecipeDeleted), string(model.RecipeRated)},
+	reflect.TypeOf(model.BatchStatus("")):        {string(model.BatchApplied), string(model.BatchFailed), string(model.BatchRolledBack)},
+	reflect.TypeOf(importer.Status("")):          {string(importer.Queued), string(importer.Running), string(importer.Done), string(importer.Failed), string(importer.Cancelled)},
+	reflect.TypeOf(importer.Format("")):          {string(importer.CSV), string(importer.JSON), string(importer.NDJSON)},
+	reflect.TypeOf(importer.Semantics("")):       {string(importer.Insert), string(importer.Upsert)},
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	dateTimeType = reflect.TypeOf(model.DateTime{})
+)
+
+//schemas builds component schemas out of Go types, so JSON tags stay the single source of field names
+type schemas map[string]*Schema
+
+func (s schemas) of(v interface{}) *Schema {
+	return s.schema(reflect.TypeOf(v))
+}
+
+func (s schemas) schema(t reflect.Type) *Schema {
+	if values, ok := enums[t]; ok {
+		return &Schema{Type: "string", Enum: values}
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case dateTimeType:
+		return &Schema{Type: "string", Format: "date-time", Nullable: true}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := s.schema(t.Elem())
+		if schema.Ref != "" {
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice:
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Struct:
+		if _, ok := s[t.Name()]; !ok {
+			object := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+			s[t.Name()] = object
+			for i := 0; i < t.NumField(); i++ {
+				if name := handler.JSONName(t.Field(i)); name != "" {
+					object.Properties[name] = s.schema(t.Field(i).Type)
+				}
+			}
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	}
+	return &Schema{}
+}
+
+//content offers the same schema in every given media type
+func content(schema *Schema, mediaTypes ...string) map[string]MediaType {
+	c := make(map[string]MediaType)
+	for _, mediaType := range mediaTypes {
+		c[mediaType] = MediaType{Schema: schema}
+	}
+	return c
+}
+
+func errorResponse(description string) Response {
+	return Response{
+		Description: description,
+		Content:     content(&Schema{Ref: "#/components/schemas/Problem"}, handler.MIMEApplicationProblemJSON),
+	}
+}
+
+func pathParameter(name string) Parameter {
+	return Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}}
+}
+
+func queryParameter(name, description string, schema *Schema) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+//NewOpenAPI describes every route of NewRecipesServer, keep them in sync (server_test checks it)
+func NewOpenAPI() *OpenAPI {
+	s := schemas{}
+	s.of(handler.Problem{})
+	s.of(model.Event{})
+	recipe := s.of(model.Recipe{})
+	recipes := &Schema{Type: "array", Items: recipe}
+	negotiated := []string{echo.MIMEApplicationJSON, echo.MIMEApplicationXML, handler.MIMETextCSV, handler.MIMEApplicationMsgpack}
+	job := content(s.of(importer.Job{}), echo.MIMEApplicationJSON)
+	recipeID := pathParameter("recipeID")
+	importID := pathParameter("importID")
+	invalid := errorResponse("Recipe breaks validation rules, violations lists all of them")
+	fields := queryParameter(handler.Fields, "Comma separated JSON names of fields to return, nested ones are dot separated", &Schema{Type: "string"})
+
+	paths := map[string]PathItem{
+		recipesPath: {
+			"get": {
+				Summary:     "List recipes, by gousto reference or by ids",
+				OperationID: "getRecipesList",
+				Parameters: []Parameter{
+					queryParameter(handler.Limit, "Page size, all recipes when missing", &Schema{Type: "integer"}),
+					queryParameter(handler.Page, "Page number starting from 1", &Schema{Type: "integer"}),
+					queryParameter(handler.GoustoReference, "Only recipes with this gousto reference", &Schema{Type: "integer"}),
+					queryParameter(handler.IDs, "Comma separated ids, response is then BatchGetResponse", &Schema{Type: "string"}),
+					fields,
+				},
+				Responses: map[string]Response{
+					"200": {Description: "Recipes", Content: content(&Schema{OneOf: []*Schema{recipes, s.of(handler.BatchGetResponse{})}}, negotiated...)},
+					"400": errorResponse("Incorrect query"),
+					"406": errorResponse("Unsupported Accept"),
+				},
+			},
+			"post": {
+				Summary:     "Create recipe, id is assigned by the service",
+				OperationID: "createRecipe",
+				RequestBody: &RequestBody{Required: true, Content: content(recipe, negotiated...)},
+				Responses: map[string]Response{
+					"201": {Description: "Created"},
+					"400": errorResponse("Incorrect body"),
+					"409": errorResponse("Recipe or gousto reference already exists"),
+					"415": errorResponse("Unsupported Content-Type"),
+					"422": invalid,
+				},
+			},
+		},
+		recipesPath + ":batch": {
+			"post": {
+				Summary:     "Apply create, update and delete operations all or nothing",
+				OperationID: "batchRecipes",
+				RequestBody: &RequestBody{Required: true, Content: content(s.of(handler.BatchWriteRequest{}), echo.MIMEApplicationJSON, echo.MIMEApplicationXML, handler.MIMEApplicationMsgpack)},
+				Responses: map[string]Response{
+					"200": {Description: "All operations applied", Content: content(s.of(handler.BatchWriteResponse{}), negotiated...)},
+					"400": errorResponse("Incorrect body or too many operations"),
+					"409": {Description: "Nothing applied, see failed operations", Content: content(s.of(handler.BatchWriteResponse{}), negotiated...)},
+					"415": errorResponse("Unsupported Content-Type"),
+				},
+			},
+		},
+		recipesPath + "/export": {
+			"get": {
+				Summary:     "Stream whole catalogue as a file",
+				OperationID: "exportRecipes",
+				Parameters: []Parameter{
+					queryParameter(handler.Format, "File format, json when missing", &Schema{Type: "string", Enum: []string{"csv", "json", "ndjson", "parquet"}}),
+					queryParameter(handler.GoustoReference, "Only recipes with this gousto reference", &Schema{Type: "integer"}),
+				},
+				Responses: map[string]Response{
+					"200": {Description: "Recipes file", Content: map[string]MediaType{
+						handler.MIMETextCSV:            {Schema: &Schema{Type: "string"}},
+						echo.MIMEApplicationJSON:       {Schema: recipes},
+						handler.MIMEApplicationNDJSON:  {Schema: &Schema{Type: "string"}},
+						handler.MIMEApplicationParquet: {Schema: &Schema{Type: "string", Format: "binary"}},
+					}},
+					"400": errorResponse("Unknown format"),
+				},
+			},
+		},
+		recipesPath + "/{recipeID}": {
+			"get": {
+				Summary:     "Get recipe",
+				OperationID: "getRecipe",
+				Parameters:  []Parameter{recipeID, fields},
+				Responses: map[string]Response{
+					"200": {Description: "Recipe", Content: content(recipe, negotiated...)},
+					"400": errorResponse("Incorrect recipeID or fields"),
+					"404": errorResponse("Recipe not found"),
+					"406": errorResponse("Unsupported Accept"),
+				},
+			},
+			"put": {
+				Summary:     "Replace recipe",
+				OperationID: "updateRecipe",
+				Parameters:  []Parameter{recipeID},
+				RequestBody: &RequestBody{Required: true, Content: content(recipe, negotiated...)},
+				Responses: map[string]Response{
+					"200": {Description: "Updated"},
+					"400": errorResponse("Incorrect recipeID or body"),
+					"404": errorResponse("Recipe not found"),
+					"409": errorResponse("Gousto reference already used"),
+					"415": errorResponse("Unsupported Content-Type"),
+					"422": invalid,
+				},
+			},
+		},
+		recipesPath + "/slug/{slug}": {
+			"get": {
+				Summary:     "Get recipe by slug, old slugs redirect to the current one",
+				OperationID: "getRecipeBySlug",
+				Parameters:  []Parameter{pathParameter("slug"), fields},
+				Responses: map[string]Response{
+					"200": {Description: "Recipe", Content: content(recipe, negotiated...)},
+					"301": {Description: "Recipe was renamed, Location has its current slug"},
+					"404": errorResponse("Recipe not found"),
+				},
+			},
+		},
+		recipesPath + "/gousto_references/conflicts": {
+			"get": {
+				Summary:     "Gousto references shared by more than one recipe",
+				OperationID: "getGoustoReferenceConflicts",
+				Responses: map[string]Response{
+					"200": {Description: "Recipe ids by gousto reference", Content: content(s.of(map[int][]int{}), echo.MIMEApplicationJSON)},
+				},
+			},
+		},
+		recipesPath + "/{recipeID}/rates": {
+			"post": {
+				Summary:     "Rate recipe",
+				OperationID: "rateRecipe",
+				Parameters:  []Parameter{recipeID},
+				RequestBody: &RequestBody{Required: true, Content: content(s.of(model.RecipeRate{}), echo.MIMEApplicationJSON, echo.MIMEApplicationXML, handler.MIMEApplicationMsgpack)},
+				Responses: map[string]Response{
+					"201": {Description: "Rated"},
+					"400": errorResponse("Incorrect recipeID or body"),
+					"404": errorResponse("Recipe not found"),
+					"415": errorResponse("Unsupported Content-Type"),
+				},
+			},
+		},
+		recipesPath + "/events": {
+			"get": {
+				Summary:     "Stream recipe changes as Server-Sent Events, reset event means some were lost and recipes have to be read again",
+				OperationID: "streamEvents",
+				Parameters: []Parameter{
+					{Name: handler.HeaderLastEventID, In: "header", Description: "Resume after this event", Schema: &Schema{Type: "integer"}},
+				},
+				Responses: map[string]Response{
+					"200": {Description: "Event stream, data of each event is JSON encoded Event", Content: map[string]MediaType{
+						handler.MIMETextEventStream: {Schema: &Schema{Type: "string"}},
+					}},
+					"400": errorResponse("Incorrect Last-Event-ID"),
+				},
+			},
+		},
+		importsPath: {
+			"post": {
+				Summary:     "Queue bulk import, format is taken from Content-Type",
+				OperationID: "createImport",
+				Parameters: []Parameter{
+					queryParameter(handler.Semantics, "What happens with existing recipes, insert when missing", s.of(importer.Semantics(""))),
+				},
+				RequestBody: &RequestBody{Required: true, Content: map[string]MediaType{
+					handler.MIMETextCSV:           {Schema: &Schema{Type: "string"}},
+					echo.MIMEApplicationJSON:      {Schema: recipes},
+					handler.MIMEApplicationNDJSON: {Schema: &Schema{Type: "string"}},
+				}},
+				Responses: map[string]Response{
+					"202": {Description: "Queued, Location points to the import", Content: job},
+					"400": errorResponse("Incorrect semantics"),
+					"413": errorResponse("Upload too large"),
+					"415": errorResponse("Unsupported Content-Type"),
+					"503": errorResponse("Too many imports queued"),
+				},
+			},
+		},
+		importsPath + "/{importID}": {
+			"get": {
+				Summary:     "Get import progress",
+				OperationID: "getImport",
+				Parameters:  []Parameter{importID},
+				Responses: map[string]Response{
+					"200": {Description: "Import", Content: job},
+					"404": errorResponse("Import not found"),
+				},
+			},
+			"delete": {
+				Summary:     "Cancel import, rows imported so far stay in place",
+				OperationID: "cancelImport",
+				Parameters:  []Parameter{importID},
+				Responses: map[string]Response{
+					"202": {Description: "Cancelling", Content: job},
+					"404": errorResponse("Import not found"),
+					"409": errorResponse("Import already finished"),
+				},
+			},
+		},
+		openAPIPath: {
+			"get": {
+				Summary:     "This document",
+				OperationID: "getOpenAPI",
+				Responses: map[string]Response{
+					"200": {Description: "OpenAPI 3 document", Content: content(&Schema{Type: "object"}, echo.MIMEApplicationJSON)},
+				},
+			},
+		},
+	}
+	//only operations mirrored under /v2 have a successor
+	for _, path := range []string{recipesPath, recipesPath + "/{recipeID}"} {
+		for _, operation := range paths[path] {
+			operation.Deprecated = true
+		}
+	}
+
+	recipeV2 := s.of(handler.RecipeV2{})
+	negotiatedV2 := []string{echo.MIMEApplicationJSON, echo.MIMEApplicationXML, handler.MIMEApplicationMsgpack}
+	paths[recipesV2Path] = PathItem{
+		"get": {
+			Summary:     "List recipes in v2 shape",
+			OperationID: "getRecipesListV2",
+			Parameters: []Parameter{
+				queryParameter(handler.Limit, "Page size, all recipes when missing", &Schema{Type: "integer"}),
+				queryParameter(handler.Page, "Page number starting from 1", &Schema{Type: "integer"}),
+				queryParameter(handler.GoustoReference, "Only recipes with this gousto reference", &Schema{Type: "integer"}),
+				fields,
+			},
+			Responses: map[string]Response{
+				"200": {Description: "Recipes", Content: content(&Schema{Type: "array", Items: recipeV2}, negotiatedV2...)},
+				"400": errorResponse("Incorrect query"),
+				"406": errorResponse("Unsupported Accept"),
+			},
+		},
+		"post": {
+			Summary:     "Create recipe, id is assigned by the service",
+			OperationID: "createRecipeV2",
+			RequestBody: &RequestBody{Required: true, Content: content(recipeV2, negotiatedV2...)},
+			Responses: map[string]Response{
+				"201": {Description: "Created recipe, Location points to it", Content: content(recipeV2, negotiatedV2...)},
+				"400": errorResponse("Incorrect body"),
+				"409": errorResponse("Recipe or gousto reference already exists"),
+				"415": errorResponse("Unsupported Content-Type"),
+				"422": invalid,
+			},
+		},
+	}
+	paths[recipesV2Path+"/{recipeID}"] = PathItem{
+		"get": {
+			Summary:     "Get recipe in v2 shape",
+			OperationID: "getRecipeV2",
+			Parameters:  []Parameter{recipeID, fields},
+			Responses: map[string]Response{
+				"200": {Description: "Recipe", Content: content(recipeV2, negotiatedV2...)},
+				"400": errorResponse("Incorrect recipeID or fields"),
+				"404": errorResponse("Recipe not found"),
+				"406": errorResponse("Unsupported Accept"),
+			},
+		},
+		"put": {
+			Summary:     "Replace recipe, id is taken from the path",
+			OperationID: "updateRecipeV2",
+			Parameters:  []Parameter{recipeID},
+			RequestBody: &RequestBody{Required: true, Content: content(recipeV2, negotiatedV2...)},
+			Responses: map[string]Response{
+				"200": {Description: "Updated recipe", Content: content(recipeV2, negotiatedV2...)},
+				"400": errorResponse("Incorrect recipeID or body"),
+				"404": errorResponse("Recipe not found"),
+				"409": errorResponse("Gousto reference already used"),
+				"415": errorResponse("Unsupported Content-Type"),
+				"422": invalid,
+			},
+		},
+	}
+
+	//GraphQL responses are described by the GraphQL schema itself, this is only the envelope
+	s["GraphQLResponse"] = &Schema{Type: "object", Properties: map[string]*Schema{
+		"data": {Type: "object", Nullable: true},
+		"errors": {Type: "array", Items: &Schema{Type: "object", Properties: map[string]*Schema{
+			"message": {Type: "string"},
+			"locations": {Type: "array", Nullable: true, Items: &Schema{Type: "object", Properties: map[string]*Schema{
+				"line":   {Type: "integer"},
+				"column": {Type: "integer"},
+			}}},
+			"path":       {Type: "array", Items: &Schema{}},
+			"extensions": {Type: "object"},
+		}}},
+	}}
+	graphQLResult := Response{
+		Description: "Result, errors of the query itself are part of it",
+		Content:     content(&Schema{Ref: "#/components/schemas/GraphQLResponse"}, echo.MIMEApplicationJSON),
+	}
+	paths[graphQLPath] = PathItem{
+		"get": {
+			Summary:     "Run GraphQL query, mutations have to be sent with POST",
+			OperationID: "getGraphQL",
+			Parameters: []Parameter{
+				queryParameter("query", "GraphQL document", &Schema{Type: "string"}),
+				queryParameter("variables", "JSON encoded variables", &Schema{Type: "string"}),
+				queryParameter("operationName", "Operation to run when query has more of them", &Schema{Type: "string"}),
+			},
+			Responses: map[string]Response{
+				"200": graphQLResult,
+				"400": errorResponse("Missing query or incorrect variables"),
+				"405": errorResponse("Mutation sent with GET"),
+			},
+		},
+		"post": {
+			Summary:     "Run GraphQL query or mutation",
+			OperationID: "postGraphQL",
+			RequestBody: &RequestBody{Required: true, Content: content(s.of(handler.GraphQLRequest{}), echo.MIMEApplicationJSON)},
+			Responses: map[string]Response{
+				"200": graphQLResult,
+				"400": errorResponse("Missing query or incorrect body"),
+				"415": errorResponse("Unsupported Content-Type"),
+			},
+		},
+	}
+
+	webhook := s.of(webhooks.Webhook{})
+	webhookID := pathParameter("webhookID")
+	paths[webhooksPath] = PathItem{
+		"post": {
+			Summary:     "Subscribe URL to recipe events, deliveries are signed with the secret in " + webhooks.SignatureHeader,
+			OperationID: "createWebhook",
+			RequestBody: &RequestBody{Required: true, Content: content(webhook, echo.MIMEApplicationJSON)},
+			Responses: map[string]Response{
+				"201": {Description: "Subscribed, Location points to the webhook, secret isn't returned", Content: content(webhook, echo.MIMEApplicationJSON)},
+				"400": errorResponse("Incorrect body"),
+				"415": errorResponse("Unsupported Content-Type"),
+				"422": errorResponse("Incorrect url, events or missing secret"),
+			},
+		},
+		"get": {
+			Summary:     "List webhooks",
+			OperationID: "getWebhooks",
+			Responses: map[string]Response{
+				"200": {Description: "Webhooks", Content: content(&Schema{Type: "array", Items: webhook}, echo.MIMEApplicationJSON)},
+			},
+		},
+	}
+	paths[webhooksPath+"/{webhookID}"] = PathItem{
+		"get": {
+			Summary:     "Get webhook",
+			OperationID: "getWebhook",
+			Parameters:  []Parameter{webhookID},
+			Responses: map[string]Response{
+				"200": {Description: "Webhook", Content: content(webhook, echo.MIMEApplicationJSON)},
+				"404": errorResponse("Webhook not found"),
+			},
+		},
+		"delete": {
+			Summary:     "Unsubscribe, pending retries are dropped",
+			OperationID: "deleteWebhook",
+			Parameters:  []Parameter{webhookID},
+			Responses: map[string]Response{
+				"204": {Description: "Deleted"},
+				"404": errorResponse("Webhook not found"),
+			},
+		},
+	}
+	paths[webhooksPath+"/dead_letters"] = PathItem{
+		"get": {
+			Summary:     "Deliveries which failed every attempt, the oldest first",
+			OperationID: "getDeadLetters",
+			Responses: map[string]Response{
+				"200": {Description: "Dead letters", Content: content(&Schema{Type: "array", Items: s.of(webhooks.DeadLetter{})}, echo.MIMEApplicationJSON)},
+			},
+		},
+	}
+	paths[webhooksPath+"/dead_letters/{deliveryID}/redeliver"] = PathItem{
+		"post": {
+			Summary:     "Try dead letter again with fresh attempts",
+			OperationID: "redeliverDeadLetter",
+			Parameters:  []Parameter{pathParameter("deliveryID")},
+			Responses: map[string]Response{
+				"202": {Description: "Redelivering"},
+				"404": errorResponse("Dead letter or its webhook not found"),
+			},
+		},
+	}
+
+	healthReport := content(s.of(health.Report{}), echo.MIMEApplicationJSON)
+	paths[livePath] = PathItem{
+		"get": {
+			Summary:     "Liveness, the process is up and serves HTTP",
+			OperationID: "getLiveness",
+			Responses: map[string]Response{
+				"200": {Description: "Alive", Content: healthReport},
+			},
+		},
+	}
+	paths[readyPath] = PathItem{
+		"get": {
+			Summary:     "Readiness, recipes are loaded and every registered check passes",
+			OperationID: "getReadiness",
+			Responses: map[string]Response{
+				"200": {Description: "Ready", Content: healthReport},
+				"503": {Description: "Some check fails or the service is shutting down", Content: healthReport},
+			},
+		},
+	}
+	paths[metricsPath] = PathItem{
+		"get": {
+			Summary:     "Request, model and import metrics in Prometheus exposition format",
+			OperationID: "getMetrics",
+			Responses: map[string]Response{
+				"200": {Description: "Metrics", Content: map[string]MediaType{
+					echo.MIMETextPlain: {Schema: &Schema{Type: "string"}},
+				}},
+			},
+		},
+	}
+
+	return &OpenAPI{
+		OpenAPI:    "3.0.3",
+		Info:       OpenAPIInfo{Title: "svc-recipes", Version: "1.0.0"},
+		Paths:      paths,
+		Components: OpenAPIComponents{Schemas: s},
+	}
+}
+
+func serveOpenAPI(spec *OpenAPI) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, spec)
+	}
+}
@@ -2,46 +2,227 @@ package server
 
 import (
 	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/gobonoid/svc-recipes/health"
 	"github.com/gobonoid/svc-recipes/interface/rest/handler"
+	"github.com/gobonoid/svc-recipes/metrics"
+	"github.com/gobonoid/svc-recipes/tracing"
 	"github.com/labstack/echo"
 	echoMiddleware "github.com/labstack/echo/middleware"
 	"github.com/pkg/errors"
 	"github.com/sandalwing/echo-logrusmiddleware"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/context"
 )
 
 const (
-	recipesPath = "/recipes"
+	recipesPath   = "/recipes"
+	recipesV2Path = "/v2/recipes"
+	importsPath   = "/imports"
+	graphQLPath   = "/graphql"
+	webhooksPath  = "/webhooks"
+	livePath      = "/healthz"
+	readyPath     = "/readyz"
+	metricsPath   = "/metrics"
 )
 
+//alwaysServed routes answer while recipes are loading, orchestrator and scrapers need them from the start
+var alwaysServed = map[string]bool{livePath: true, readyPath: true, metricsPath: true, openAPIPath: true}
+
 type RecipesServer struct {
-	echo *echo.Echo
-	port int
+	echo    *echo.Echo
+	port    int
+	health  *health.Registry
+	loading int32
 }
 
-func NewRecipesServer(port int, log *logrus.Logger, handler handler.RecipesHandler) *RecipesServer {
+func NewRecipesServer(port int, log *logrus.Logger, recipesHandler handler.RecipesHandler, importsHandler handler.ImportsHandler, graphQLHandler handler.GraphQLHandler, webhooksHandler handler.WebhooksHandler, registry *health.Registry, m *metrics.Metrics) *RecipesServer {
 	e := echo.New()
 	e.Logger = logrusmiddleware.Logger{Logger: log}
 	e.HideBanner = true
-	e.Use(logrusmiddleware.Hook())
+	e.HTTPErrorHandler = handler.HTTPErrorHandler
+	e.Use(echoMiddleware.RequestID())
+	routes := make(map[string]bool)
+	//both outside of Recover, so panics are counted, traced and logged as the 500 they end up with
+	e.Use(instrumented(m, routes))
+	e.Use(traced(log, routes))
 	e.Use(echoMiddleware.Recover())
-	s := &RecipesServer{port: port}
+	s := &RecipesServer{port: port, health: registry}
+	e.Use(s.held)
 
+	//v1 stays at /recipes for existing clients, /v2 has the same recipes in RecipeV2 shape. Only routes mirrored
+	//under /v2 are deprecated, the rest has no successor.
 	recipes := e.Group(recipesPath)
+	successor := deprecated(recipesV2Path)
+	//batch get by ?ids= has no /v2 counterpart
+	listSuccessor := deprecated(recipesV2Path, "ids")
+
+	//Handlers bind bodies by Content-Type and validate recipes themselves, see handler.bind and model.Recipe.Validate
+	recipes.POST("", recipesHandler.CreateRecipe, successor)
+	recipes.POST(":method", recipesHandler.RecipesMethod) //custom methods like /recipes:batch
+	recipes.GET("", recipesHandler.GetRecipesList, listSuccessor)
+	recipes.GET("/export", recipesHandler.ExportRecipes)
+	recipes.PUT("/:recipeID", recipesHandler.UpdateRecipe, successor)
+	recipes.GET("/:recipeID", recipesHandler.GetRecipe, successor)
+	recipes.GET("/slug/:slug", recipesHandler.GetRecipeBySlug)
+	recipes.GET("/gousto_references/conflicts", recipesHandler.GetGoustoReferenceConflicts)
+	recipes.POST("/:recipeID/rates", recipesHandler.RateRecipe)
+
+	//Shutdown closes open change feed streams
+	streams, closeStreams := context.WithCancel(context.Background())
+	e.Server.RegisterOnShutdown(closeStreams)
+	recipes.GET("/events", recipesHandler.StreamEvents(streams.Done()))
+
+	recipesV2 := e.Group(recipesV2Path)
+	recipesV2.POST("", recipesHandler.CreateRecipeV2)
+	recipesV2.GET("", recipesHandler.GetRecipesListV2)
+	recipesV2.PUT("/:recipeID", recipesHandler.UpdateRecipeV2)
+	recipesV2.GET("/:recipeID", recipesHandler.GetRecipeV2)
+
+	imports := e.Group(importsPath)
+	imports.POST("", importsHandler.CreateImport)
+	imports.GET("/:importID", importsHandler.GetImport)
+	imports.DELETE("/:importID", importsHandler.CancelImport)
 
-	//Production like project  would use binding and validating middleware, I really value my time here
-	recipes.POST("", handler.CreateRecipe)
-	recipes.GET("", handler.GetRecipesList)
-	recipes.PUT("/:recipeID", handler.UpdateRecipe)
-	recipes.GET("/:recipeID", handler.GetRecipe)
-	recipes.POST("/:recipeID/rates", handler.RateRecipe)
+	webhooks := e.Group(webhooksPath)
+	webhooks.POST("", webhooksHandler.CreateWebhook)
+	webhooks.GET("", webhooksHandler.GetWebhooks)
+	webhooks.GET("/dead_letters", webhooksHandler.GetDeadLetters)
+	webhooks.POST("/dead_letters/:deliveryID/redeliver", webhooksHandler.RedeliverDeadLetter)
+	webhooks.GET("/:webhookID", webhooksHandler.GetWebhook)
+	webhooks.DELETE("/:webhookID", webhooksHandler.DeleteWebhook)
+
+	e.GET(graphQLPath, graphQLHandler.Serve)
+	e.POST(graphQLPath, graphQLHandler.Serve)
+
+	healthHandler := handler.NewHealthHandler(registry)
+	e.GET(livePath, healthHandler.Live)
+	e.GET(readyPath, healthHandler.Ready)
+	e.GET(metricsPath, serveMetrics(m))
+
+	e.GET(openAPIPath, serveOpenAPI(NewOpenAPI()))
+	for _, route := range e.Routes() {
+		routes[route.Path] = true
+	}
 	s.echo = e
 	return s
 }
 
+//deprecated marks responses of an old API version and points clients to its successor. Requests with any of
+//unmirrored query params can't be served by the successor and are left alone.
+func deprecated(successor string, unmirrored ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			for _, param := range unmirrored {
+				if c.QueryParam(param) != "" {
+					return next(c)
+				}
+			}
+			header := c.Response().Header()
+			header.Set("Deprecation", "true")
+			header.Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+			return next(c)
+		}
+	}
+}
+
+//instrumented counts requests by route template. Routes are filled in once registered and only read afterwards,
+//echo leaves raw path on some unmatched requests so it can't be taken as it is.
+func instrumented(m *metrics.Metrics, routes map[string]bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			//error handler writes the response, status is known only after it
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			m.ObserveRequest(c.Request().Method, route(c, routes), c.Response().Status, time.Since(start))
+			return nil
+		}
+	}
+}
+
+//traced continues the trace of the caller from traceparent header and gives handlers a logger with trace ids.
+//Requests are logged here too, so the access log can be followed to the trace.
+func traced(log *logrus.Logger, routes map[string]bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			req := c.Request()
+			ctx := tracing.Propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+			template := route(c, routes)
+			ctx, span := tracing.Tracer().Start(ctx, req.Method+" "+template,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(semconv.HTTPMethod(req.Method), semconv.HTTPRoute(template)),
+			)
+			defer span.End()
+			c.SetRequest(req.WithContext(ctx))
+			logger := log.WithFields(tracing.LogFields(ctx))
+			c.Set(handler.LoggerKey, logger)
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			status := c.Response().Status
+			span.SetAttributes(semconv.HTTPStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+			logRequest(logger, c, time.Since(start))
+			return nil
+		}
+	}
+}
+
+//logRequest writes the access log line, fields are the ones echo-logrusmiddleware used to log
+func logRequest(logger *logrus.Entry, c echo.Context, took time.Duration) {
+	req := c.Request()
+	res := c.Response()
+	logger.WithFields(logrus.Fields{
+		"remote_ip":     c.RealIP(),
+		"host":          req.Host,
+		"uri":           req.RequestURI,
+		"method":        req.Method,
+		"path":          req.URL.Path,
+		"referer":       req.Referer(),
+		"user_agent":    req.UserAgent(),
+		"request_id":    res.Header().Get(echo.HeaderXRequestID),
+		"status":        res.Status,
+		"latency":       strconv.FormatInt(took.Nanoseconds()/1000, 10),
+		"latency_human": took.String(),
+		"bytes_in":      req.Header.Get(echo.HeaderContentLength),
+		"bytes_out":     strconv.FormatInt(res.Size, 10),
+	}).Info("Handled request")
+}
+
+//route is the template of matched route, paths which aren't routes go under one name to keep metrics and spans bounded
+func route(c echo.Context, routes map[string]bool) string {
+	if routes[c.Path()] {
+		return c.Path()
+	}
+	return "unmatched"
+}
+
+func serveMetrics(m *metrics.Metrics) echo.HandlerFunc {
+	h := m.Handler()
+	return func(c echo.Context) error {
+		h.ServeHTTP(c.Response(), c.Request())
+		return nil
+	}
+}
+
+//ServeHTTP lets the server run without Start, e.g. in httptest.Server
+func (s *RecipesServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.echo.ServeHTTP(w, r)
+}
+
 func (s *RecipesServer) Start() {
 	go func() {
 		if err := s.echo.Start(fmt.Sprintf(":%d", s.port)); err != nil {
@@ -51,9 +232,34 @@ func (s *RecipesServer) Start() {
 	}()
 }
 
-func (s *RecipesServer) Stop() {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+//Loading holds every route but health, metrics and the spec with 503 until loaded is called. Writes made while
+//the data source is imported would be overwritten by it or make it fail, readiness protects only those who check it.
+func (s *RecipesServer) Loading() (loaded func()) {
+	atomic.StoreInt32(&s.loading, 1)
+	return func() {
+		atomic.StoreInt32(&s.loading, 0)
+	}
+}
+
+//held answers for routes Loading holds
+func (s *RecipesServer) held(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if atomic.LoadInt32(&s.loading) == 1 && !alwaysServed[c.Path()] {
+			c.Response().Header().Set("Retry-After", "1")
+			return echo.NewHTTPError(http.StatusServiceUnavailable, "recipes are still loading")
+		}
+		return next(c)
+	}
+}
+
+//Stop fails readiness and keeps serving for drain, so load balancers notice before the server stops taking requests.
+//Then it waits for running requests until ctx is done, event streams are closed right away.
+func (s *RecipesServer) Stop(ctx context.Context, drain time.Duration) {
+	s.health.Drain()
+	select {
+	case <-time.After(drain):
+	case <-ctx.Done():
+	}
 	if err := s.echo.Shutdown(ctx); err != nil {
 		s.echo.Logger.Fatal(errors.Wrap(err, "failed to shutdown Recipes Server"))
 	}
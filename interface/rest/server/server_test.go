@@ -0,0 +1,427 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gobonoid/svc-recipes/health"
+	"github.com/gobonoid/svc-recipes/importer"
+	"github.com/gobonoid/svc-recipes/interface/rest/handler"
+	"github.com/gobonoid/svc-recipes/metrics"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/gobonoid/svc-recipes/webhooks"
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/net/context"
+)
+
+var pathParam = regexp.MustCompile(`/:(\w+)`)
+
+func newTestServer(t *testing.T) (*RecipesServer, func()) {
+	log := logrus.New()
+	log.Out = ioutil.Discard
+	return newLoggedTestServer(t, log)
+}
+
+func newLoggedTestServer(t *testing.T, log *logrus.Logger) (*RecipesServer, func()) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Chilli", GoustoReference: 59}))
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 2, Title: "Fish Pie", GoustoReference: 59}))
+	recipesImporter := importer.NewImporter(recipesModel, 1)
+	dispatcher := webhooks.NewDispatcher(recipesModel, logrus.New())
+	graphQLHandler, err := handler.NewGraphQLHandler(recipesModel)
+	require.NoError(t, err)
+	s := NewRecipesServer(0, log, handler.NewRecipesHandler(recipesModel), handler.NewImportsHandler(recipesImporter), graphQLHandler, handler.NewWebhooksHandler(dispatcher), health.NewRegistry(), metrics.New())
+	return s, func() {
+		recipesImporter.Stop()
+		dispatcher.Stop()
+	}
+}
+
+//specPath turns echo route into OpenAPI path, custom methods (/recipes:method) match any /recipes:verb in the spec
+func specPath(spec *OpenAPI, method string, path string) (string, bool) {
+	if i := strings.Index(path, ":method"); i > 0 && path[i-1] != '/' {
+		for p, item := range spec.Paths {
+			if strings.HasPrefix(p, path[:i+1]) && item[strings.ToLower(method)] != nil {
+				return p, true
+			}
+		}
+		return "", false
+	}
+	p := pathParam.ReplaceAllString(path, "/{$1}")
+	item, ok := spec.Paths[p]
+	return p, ok && item[strings.ToLower(method)] != nil
+}
+
+func TestOpenAPI_CoversRoutes(t *testing.T) {
+	s, stop := newTestServer(t)
+	defer stop()
+	spec := NewOpenAPI()
+	routed := make(map[string]bool)
+	for _, route := range s.echo.Routes() {
+		//groups register catch-all routes for 404s, handlers of these live in echo itself
+		if strings.HasPrefix(route.Name, "github.com/labstack/echo.") {
+			continue
+		}
+		p, ok := specPath(spec, route.Method, route.Path)
+		assert.True(t, ok, "%s %s is missing in openapi.json", route.Method, route.Path)
+		routed[route.Method+" "+p] = true
+	}
+	for p, item := range spec.Paths {
+		for method := range item {
+			assert.True(t, routed[strings.ToUpper(method)+" "+p], "%s %s has no route", method, p)
+		}
+	}
+}
+
+func TestOpenAPI_ResponseShapes(t *testing.T) {
+	s, stop := newTestServer(t)
+	defer stop()
+	spec := NewOpenAPI()
+	for _, tc := range []struct {
+		method, target, body, specPath string
+		status                         int
+	}{
+		{echo.GET, "/recipes/1", "", "/recipes/{recipeID}", http.StatusOK},
+		{echo.GET, "/recipes/42", "", "/recipes/{recipeID}", http.StatusNotFound},
+		{echo.GET, "/recipes", "", "/recipes", http.StatusOK},
+		{echo.GET, "/recipes?ids=1,5", "", "/recipes", http.StatusOK},
+		{echo.GET, "/recipes/gousto_references/conflicts", "", "/recipes/gousto_references/conflicts", http.StatusOK},
+		{echo.GET, "/recipes/export", "", "/recipes/export", http.StatusOK},
+		{echo.POST, "/recipes:batch", `{"operations": [{"op": "delete", "id": 2}]}`, "/recipes:batch", http.StatusOK},
+		{echo.POST, "/recipes:batch", `{"operations": [{"op": "delete", "id": 42}]}`, "/recipes:batch", http.StatusConflict},
+		{echo.POST, "/recipes:batch", `{"operations": [{"op": "create", "id": 3, "recipe": {"fat_grams": -1}}]}`, "/recipes:batch", http.StatusConflict},
+		{echo.POST, "/recipes", `{"fat_grams": -1}`, "/recipes", http.StatusUnprocessableEntity},
+		{echo.POST, "/imports", `[{"id": 3, "title": "Lamb Curry"}]`, "/imports", http.StatusAccepted},
+		{echo.GET, "/imports/1", "", "/imports/{importID}", http.StatusOK},
+		{echo.GET, "/openapi.json", "", "/openapi.json", http.StatusOK},
+		{echo.POST, "/graphql", `{"query": "{ recipe(id: 1) { id title ratings { rate } } }"}`, "/graphql", http.StatusOK},
+		{echo.GET, "/graphql?query=%7Brecipes%7Btotal_count%7D%7D", "", "/graphql", http.StatusOK},
+		{echo.GET, "/graphql?query=mutation%7Brate_recipe(id%3A1%2Crate%3A5)%7Bid%7D%7D", "", "/graphql", http.StatusMethodNotAllowed},
+		{echo.POST, "/graphql", `{"query": "{ recipe(id: 42) { nope } }"}`, "/graphql", http.StatusOK},
+		{echo.GET, "/v2/recipes", "", "/v2/recipes", http.StatusOK},
+		{echo.GET, "/v2/recipes/1", "", "/v2/recipes/{recipeID}", http.StatusOK},
+		{echo.POST, "/v2/recipes", `{"title": "Lamb Curry", "nutrition": {"fat_grams": 12}, "ingredients": ["lamb"]}`, "/v2/recipes", http.StatusCreated},
+		{echo.PUT, "/v2/recipes/1", `{"nutrition": {"fat_grams": -1}}`, "/v2/recipes/{recipeID}", http.StatusUnprocessableEntity},
+		{echo.POST, "/webhooks", `{"url": "https://partner.com/hooks", "events": ["rated"], "secret": "s3cret"}`, "/webhooks", http.StatusCreated},
+		{echo.POST, "/webhooks", `{"url": "https://partner.com/hooks", "events": ["eaten"], "secret": "s3cret"}`, "/webhooks", http.StatusUnprocessableEntity},
+		{echo.GET, "/webhooks", "", "/webhooks", http.StatusOK},
+		{echo.GET, "/webhooks/1", "", "/webhooks/{webhookID}", http.StatusOK},
+		{echo.DELETE, "/webhooks/42", "", "/webhooks/{webhookID}", http.StatusNotFound},
+		{echo.GET, "/webhooks/dead_letters", "", "/webhooks/dead_letters", http.StatusOK},
+		{echo.GET, "/healthz", "", "/healthz", http.StatusOK},
+		{echo.GET, "/readyz", "", "/readyz", http.StatusOK},
+		{echo.POST, "/webhooks/dead_letters/1/redeliver", "", "/webhooks/dead_letters/{deliveryID}/redeliver", http.StatusNotFound},
+	} {
+		name := tc.method + " " + tc.target
+		var body io.Reader
+		if tc.body != "" {
+			body = strings.NewReader(tc.body)
+		}
+		req := httptest.NewRequest(tc.method, tc.target, body)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		s.echo.ServeHTTP(rec, req)
+		require.Equal(t, tc.status, rec.Code, name)
+
+		response, ok := spec.Paths[tc.specPath][strings.ToLower(tc.method)].Responses[fmt.Sprint(tc.status)]
+		require.True(t, ok, "%s: status %d is missing in openapi.json", name, tc.status)
+		contentType, _, err := mime.ParseMediaType(rec.Header().Get(echo.HeaderContentType))
+		require.NoError(t, err, name)
+		mediaType, ok := response.Content[contentType]
+		require.True(t, ok, "%s: %s response is missing in openapi.json", name, contentType)
+		var v interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &v), name)
+		assert.NoError(t, validate(spec, mediaType.Schema, v, "$"), name)
+	}
+}
+
+//validate checks types and that objects have no properties schema doesn't know about
+func validate(spec *OpenAPI, schema *Schema, v interface{}, at string) error {
+	if schema.Ref != "" {
+		return validate(spec, spec.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")], v, at)
+	}
+	if len(schema.OneOf) > 0 {
+		for _, candidate := range schema.OneOf {
+			if validate(spec, candidate, v, at) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s matches none of oneOf", at)
+	}
+	if v == nil {
+		if schema.Nullable {
+			return nil
+		}
+		return fmt.Errorf("%s is null", at)
+	}
+	switch schema.Type {
+	case "object":
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s is not an object", at)
+		}
+		for key, value := range object {
+			property := schema.AdditionalProperties
+			if p, ok := schema.Properties[key]; ok {
+				property = p
+			}
+			if property == nil {
+				if schema.Properties == nil {
+					continue
+				}
+				return fmt.Errorf("%s.%s is not in the spec", at, key)
+			}
+			if err := validate(spec, property, value, at+"."+key); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s is not an array", at)
+		}
+		for i, item := range array {
+			if err := validate(spec, schema.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s is not a string", at)
+		}
+		if len(schema.Enum) > 0 && !contains(schema.Enum, s) {
+			return fmt.Errorf("%s: %s is not one of %v", at, s, schema.Enum)
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s is not an integer", at)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s is not a number", at)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s is not a boolean", at)
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRecipesServer_Problems(t *testing.T) {
+	s, stop := newTestServer(t)
+	defer stop()
+
+	req := httptest.NewRequest(echo.GET, "/recipes/42", nil)
+	req.Header.Set(echo.HeaderXRequestID, "req-1")
+	rec := httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, handler.MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+	var problem handler.Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, handler.Problem{
+		Type:      "/problems/not-found",
+		Title:     "Recipe not found",
+		Status:    http.StatusNotFound,
+		Detail:    model.NotFoundError.Error(),
+		Instance:  "/recipes/42",
+		RequestID: "req-1",
+	}, problem)
+
+	rec = httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/recipes?limit=x", nil))
+	problem = handler.Problem{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, "about:blank", problem.Type)
+	assert.Equal(t, http.StatusBadRequest, problem.Status)
+	assert.Equal(t, "Incorrect limit given", problem.Detail)
+	assert.NotEmpty(t, problem.RequestID, "request id is generated when client didn't send one")
+	assert.Equal(t, problem.RequestID, rec.Header().Get(echo.HeaderXRequestID))
+}
+
+func TestRecipesServer_Deprecation(t *testing.T) {
+	s, stop := newTestServer(t)
+	defer stop()
+
+	rec := httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/recipes/1", nil))
+	assert.Equal(t, "true", rec.Header().Get("Deprecation"))
+	assert.Equal(t, `</v2/recipes>; rel="successor-version"`, rec.Header().Get("Link"))
+
+	rec = httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/v2/recipes/1", nil))
+	assert.Empty(t, rec.Header().Get("Deprecation"))
+
+	//v1 routes without v2 counterpart have no successor to point to
+	req := httptest.NewRequest(echo.POST, "/recipes/1/rates", strings.NewReader(`{"rate": 5}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get("Deprecation"))
+	assert.Empty(t, rec.Header().Get("Link"))
+	rec = httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/recipes/gousto_references/conflicts", nil))
+	assert.Empty(t, rec.Header().Get("Deprecation"))
+	rec = httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/recipes?ids=1,2", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Deprecation"))
+	rec = httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/recipes?limit=1", nil))
+	assert.Equal(t, "true", rec.Header().Get("Deprecation"))
+
+	spec := NewOpenAPI()
+	assert.True(t, spec.Paths["/recipes/{recipeID}"]["put"].Deprecated)
+	assert.False(t, spec.Paths["/recipes/{recipeID}/rates"]["post"].Deprecated)
+}
+
+func TestRecipesServer_Health(t *testing.T) {
+	s, stop := newTestServer(t)
+	defer stop()
+	var failing int32 = 1
+	s.health.Register("recipes", func(ctx context.Context) error {
+		if atomic.LoadInt32(&failing) == 1 {
+			return errors.New("not loaded yet")
+		}
+		return nil
+	})
+	ready := func() (int, health.Report) {
+		rec := httptest.NewRecorder()
+		s.echo.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/readyz", nil))
+		var report health.Report
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		return rec.Code, report
+	}
+
+	status, report := ready()
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, health.StatusFailing, report.Status)
+	require.Equal(t, 1, len(report.Checks))
+	assert.Equal(t, "not loaded yet", report.Checks[0].Error)
+
+	atomic.StoreInt32(&failing, 0)
+	status, report = ready()
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, health.StatusOK, report.Checks[0].Status)
+
+	//liveness doesn't care about draining, readiness fails from the start of Stop
+	s.health.Drain()
+	status, report = ready()
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, health.StatusDraining, report.Status)
+	rec := httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRecipesServer_Loading(t *testing.T) {
+	s, stop := newTestServer(t)
+	defer stop()
+	loaded := s.Loading()
+
+	rec := httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, httptest.NewRequest(echo.POST, "/recipes", strings.NewReader(`{"id": 3, "title": "Lamb Curry"}`)))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, handler.MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+	for _, target := range []string{"/healthz", "/readyz", "/metrics", "/openapi.json"} {
+		rec = httptest.NewRecorder()
+		s.echo.ServeHTTP(rec, httptest.NewRequest(echo.GET, target, nil))
+		assert.NotEqual(t, http.StatusServiceUnavailable, rec.Code, target)
+	}
+
+	loaded()
+	rec = httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/recipes/1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRecipesServer_Metrics(t *testing.T) {
+	s, stop := newTestServer(t)
+	defer stop()
+	for _, target := range []string{"/recipes/1", "/recipes/42", "/v2/recipes/1", "/nope"} {
+		s.echo.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(echo.GET, target, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	s.echo.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Header().Get(echo.HeaderContentType), echo.MIMETextPlain))
+	body := rec.Body.String()
+	for _, line := range []string{
+		`recipes_http_requests_total{method="GET",route="/recipes/:recipeID",status="200"} 1`,
+		`recipes_http_requests_total{method="GET",route="/recipes/:recipeID",status="404"} 1`,
+		`recipes_http_requests_total{method="GET",route="/v2/recipes/:recipeID",status="200"} 1`,
+		`recipes_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+	} {
+		assert.Contains(t, body, line+"\n")
+	}
+}
+
+func TestRecipesServer_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	var logged bytes.Buffer
+	log := logrus.New()
+	log.Out = &logged
+	log.Formatter = &logrus.JSONFormatter{}
+	s, stop := newLoggedTestServer(t, log)
+	defer stop()
+
+	req := httptest.NewRequest(echo.GET, "/recipes/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	s.echo.ServeHTTP(httptest.NewRecorder(), req)
+	s.echo.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(echo.GET, "/v2/recipes/42", nil))
+
+	spans := recorder.Ended()
+	require.Equal(t, 4, len(spans))
+	assert.Equal(t, "RecipesModel.FetchOneByID", spans[0].Name())
+	assert.Equal(t, "GET /recipes/:recipeID", spans[1].Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[1].SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[1].Parent().SpanID().String(), "caller span")
+	assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+
+	//without traceparent the request starts a new trace
+	assert.Equal(t, "GET /v2/recipes/:recipeID", spans[3].Name())
+	assert.False(t, spans[3].Parent().IsValid())
+	assert.Equal(t, spans[3].SpanContext().TraceID(), spans[2].SpanContext().TraceID())
+	assert.Equal(t, codes.Error, spans[2].Status().Code, "model call failed")
+	assert.Equal(t, codes.Unset, spans[3].Status().Code, "404 isn't server error")
+
+	//access log line of the request carries its trace
+	var entry map[string]interface{}
+	require.NoError(t, json.NewDecoder(&logged).Decode(&entry))
+	assert.Equal(t, "Handled request", entry["msg"])
+	assert.Equal(t, "/recipes/1", entry["path"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", entry["trace_id"])
+	assert.Equal(t, spans[1].SpanContext().SpanID().String(), entry["span_id"])
+}
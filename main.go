@@ -1,36 +1,150 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
-	"github.com/Sirupsen/logrus"
+	"github.com/gobonoid/svc-recipes/config"
+	"github.com/gobonoid/svc-recipes/health"
+	"github.com/gobonoid/svc-recipes/importer"
+	grpcServer "github.com/gobonoid/svc-recipes/interface/grpc/server"
 	"github.com/gobonoid/svc-recipes/interface/rest/handler"
 	"github.com/gobonoid/svc-recipes/interface/rest/server"
+	"github.com/gobonoid/svc-recipes/metrics"
 	"github.com/gobonoid/svc-recipes/model"
+	"github.com/gobonoid/svc-recipes/tracing"
+	"github.com/gobonoid/svc-recipes/webhooks"
 	"github.com/pkg/errors"
-)
-
-const (
-	applicationPort = 8080 // maybe a flag...
-	csvPath         = "recipe-data.csv" //possibly I could use flag here
+	"golang.org/x/net/context"
 )
 
 func main() {
-	logger := logrus.New()
-	logger.Formatter = &logrus.JSONFormatter{}
+	cfg, err := config.Load(os.Args[1:], os.LookupEnv)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if cfg.Print {
+		printed, err := cfg.YAML()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(printed)
+		return
+	}
+	logger := cfg.Logger()
+	stopTracing, err := tracing.Setup(tracing.Settings{
+		Exporter: cfg.Tracing.Exporter,
+		File:     cfg.Tracing.File,
+		Endpoint: cfg.Tracing.Endpoint,
+		Insecure: cfg.Tracing.Insecure,
+	})
+	if err != nil {
+		logger.Fatalf("%#v", err)
+	}
 
-	csv, err := os.Open(csvPath)
+	//HTTP server starts before recipes are loaded, so orchestrator sees the process alive but not ready yet
+	registry := health.NewRegistry()
+	var imported int32
+	registry.Register("recipes", func(ctx context.Context) error {
+		if atomic.LoadInt32(&imported) == 0 {
+			return errors.Errorf("%s not loaded yet", cfg.DataSource)
+		}
+		return nil
+	})
+	serviceMetrics := metrics.New()
+	modelOptions := []model.Option{
+		model.EventsCapacity(cfg.EventsCapacity),
+		model.ObserveLockWait(serviceMetrics.ObserveLockWait),
+		model.OnParked(func(event model.Event, err error) {
+			logger.WithField("event_id", event.ID).WithField("type", event.Type).Errorf("%#v", errors.Wrap(err, "subscriber gave up on event"))
+		}),
+	}
+	if cfg.Features.UniqueGoustoReference {
+		modelOptions = append(modelOptions, model.UniqueGoustoReference())
+	}
+	recipesModel := model.NewRecipesModel(modelOptions...)
+	recipesImporter := importer.NewImporter(recipesModel, cfg.ImportsQueueSize)
+	registry.Register("importer", recipesImporter.Check)
+	serviceMetrics.WatchModel(recipesModel)
+	serviceMetrics.WatchImporter(recipesImporter)
+	dispatcher := webhooks.NewDispatcher(recipesModel, logger, webhooks.WithTimeout(cfg.Timeouts.Webhook))
+	graphQLHandler, err := handler.NewGraphQLHandler(recipesModel)
 	if err != nil {
-		logger.Fatalf("%#v", errors.Wrapf(err, "can't load csv: %s", csvPath))
+		logger.Fatalf("%#v", err)
 	}
-	recipesModel := model.NewRecipesModel()
-	recipesModel.LoadFromCSV(csv)
-	httpServer := server.NewRecipesServer(applicationPort, logger, handler.NewRecipesHandler(recipesModel))
+	httpServer := server.NewRecipesServer(
+		cfg.HTTPPort,
+		logger,
+		handler.NewRecipesHandler(recipesModel),
+		handler.NewImportsHandler(recipesImporter, handler.MaxUploadSize(int64(cfg.MaxUploadSize))),
+		graphQLHandler,
+		handler.NewWebhooksHandler(dispatcher),
+		registry,
+		serviceMetrics,
+	)
+	//recipe routes answer 503 until the import is done
+	loaded := httpServer.Loading()
 	httpServer.Start()
 
-	quit := make(chan os.Signal)
-	signal.Notify(quit, os.Interrupt)
+	csv, err := os.Open(cfg.DataSource)
+	if err != nil {
+		logger.Fatalf("%#v", errors.Wrapf(err, "can't load csv: %s", cfg.DataSource))
+	}
+	report, err := recipesModel.Import(csv, model.FailFast)
+	csv.Close()
+	for _, rowError := range report.Errors {
+		logger.WithField("line", rowError.Line).WithField("column", rowError.Column).Warn(rowError.Reason)
+	}
+	if err != nil {
+		logger.Fatalf("%#v", errors.Wrapf(err, "can't import csv: %s", cfg.DataSource))
+	}
+	atomic.StoreInt32(&imported, 1)
+	loaded()
+	logger.WithField("report", report).Info("Recipes imported")
+
+	var recipesGRPCServer *grpcServer.RecipesServer
+	if cfg.Features.GRPC {
+		recipesGRPCServer = grpcServer.NewRecipesServer(cfg.GRPCPort, logger, recipesModel)
+		recipesGRPCServer.Start()
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
-	httpServer.Stop()
+	//both servers keep serving for drain timeout while readiness fails, then drain running calls at the same time.
+	//Imports and webhooks are stopped once nothing can queue new ones.
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Drain+cfg.Timeouts.Shutdown)
+	defer cancel()
+	var stopping sync.WaitGroup
+	stopping.Add(1)
+	go func() {
+		defer stopping.Done()
+		httpServer.Stop(ctx, cfg.Timeouts.Drain)
+	}()
+	if recipesGRPCServer != nil {
+		stopping.Add(1)
+		go func() {
+			defer stopping.Done()
+			time.Sleep(cfg.Timeouts.Drain)
+			recipesGRPCServer.Stop(ctx)
+		}()
+	}
+	stopping.Wait()
+	recipesImporter.Stop()
+	dispatcher.Stop()
+	//spans of the last requests are still in the batch
+	if err := stopTracing(ctx); err != nil {
+		logger.Errorf("%#v", errors.Wrap(err, "failed to flush spans"))
+	}
 }
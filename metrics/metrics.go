@@ -0,0 +1,155 @@
+//Package metrics exposes request, model and import metrics in Prometheus format. Model and importer figures are read
+//from them when scraped, only request latency and lock waits are recorded as they happen.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gobonoid/svc-recipes/importer"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "recipes"
+
+//Sources of imported rows, the data source is loaded through model.Import on start, uploads go through importer
+const (
+	SourceDataSource = "data_source"
+	SourceUpload     = "upload"
+)
+
+//ImporterStatsReporter is the part of importer.Importer metrics need
+type ImporterStatsReporter interface {
+	Stats() importer.Stats
+}
+
+//Metrics has its own registry, so tests don't share collectors through the global one
+type Metrics struct {
+	registry  *prometheus.Registry
+	requests  *prometheus.CounterVec
+	durations *prometheus.HistogramVec
+	lockWait  prometheus.Histogram
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route template and response status.",
+		}, []string{"method", "route", "status"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route template and response status, event streams last as long as the client stays.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		lockWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "model_lock_wait_seconds",
+			Help:      "Time model calls waited for the model lock.",
+			Buckets:   prometheus.ExponentialBuckets(0.000001, 10, 7),
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.durations,
+		m.lockWait,
+	)
+	return m
+}
+
+//ObserveRequest takes route template rather than path, so recipe ids don't end up as label values
+func (m *Metrics) ObserveRequest(method string, route string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	m.requests.WithLabelValues(method, route, code).Inc()
+	m.durations.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+//ObserveLockWait is meant for model.ObserveLockWait option
+func (m *Metrics) ObserveLockWait(wait time.Duration) {
+	m.lockWait.Observe(wait.Seconds())
+}
+
+//WatchModel exports recipe and rate counts, outbox lag and rows of model.Import
+func (m *Metrics) WatchModel(stats model.RecipesStatsReporter) {
+	m.registry.MustRegister(modelCollector{stats: stats})
+}
+
+//WatchImporter exports rows of uploads
+func (m *Metrics) WatchImporter(stats ImporterStatsReporter) {
+	m.registry.MustRegister(importerCollector{stats: stats})
+}
+
+//Handler serves the exposition format, errors of single collectors are reported in the response
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})
+}
+
+var (
+	recipesDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "model", "recipes"), "Recipes in the model.", nil, nil)
+	ratesDesc   = prometheus.NewDesc(prometheus.BuildFQName(namespace, "model", "rates"), "Rates of recipes in the model.", nil, nil)
+	pendingDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "model", "events_pending"),
+		"Events in the outbox some subscriber hasn't handled yet, growing means a subscriber lags.", nil, nil)
+	parkedDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "model", "events_parked_total"),
+		"Events subscribers ran out of attempts for.", nil, nil)
+	evictedDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "model", "events_evicted_total"),
+		"Events dropped from the outbox beyond its retention before every subscriber handled them.", nil, nil)
+)
+
+//importRowsDesc differs by source only, so both collectors can export the same metric
+func importRowsDesc(source string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "import", "rows_total"),
+		"Imported rows by source and outcome, failed rows were invalid or clashed with existing recipes.",
+		[]string{"outcome"},
+		prometheus.Labels{"source": source},
+	)
+}
+
+type modelCollector struct {
+	stats model.RecipesStatsReporter
+}
+
+func (c modelCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- recipesDesc
+	ch <- ratesDesc
+	ch <- pendingDesc
+	ch <- parkedDesc
+	ch <- evictedDesc
+	ch <- importRowsDesc(SourceDataSource)
+}
+
+func (c modelCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.stats.Stats()
+	rows := importRowsDesc(SourceDataSource)
+	ch <- prometheus.MustNewConstMetric(recipesDesc, prometheus.GaugeValue, float64(stats.Recipes))
+	ch <- prometheus.MustNewConstMetric(ratesDesc, prometheus.GaugeValue, float64(stats.Rates))
+	ch <- prometheus.MustNewConstMetric(pendingDesc, prometheus.GaugeValue, float64(stats.EventsPending))
+	ch <- prometheus.MustNewConstMetric(parkedDesc, prometheus.CounterValue, float64(stats.EventsParked))
+	ch <- prometheus.MustNewConstMetric(evictedDesc, prometheus.CounterValue, float64(stats.EventsEvicted))
+	ch <- prometheus.MustNewConstMetric(rows, prometheus.CounterValue, float64(stats.RowsImported), "imported")
+	ch <- prometheus.MustNewConstMetric(rows, prometheus.CounterValue, float64(stats.RowsSkipped), "failed")
+}
+
+type importerCollector struct {
+	stats ImporterStatsReporter
+}
+
+func (c importerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- importRowsDesc(SourceUpload)
+}
+
+func (c importerCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.stats.Stats()
+	rows := importRowsDesc(SourceUpload)
+	ch <- prometheus.MustNewConstMetric(rows, prometheus.CounterValue, float64(stats.RowsImported), "imported")
+	ch <- prometheus.MustNewConstMetric(rows, prometheus.CounterValue, float64(stats.RowsFailed), "failed")
+}
@@ -0,0 +1,68 @@
+package metrics_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobonoid/svc-recipes/importer"
+	"github.com/gobonoid/svc-recipes/metrics"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type importerStats importer.Stats
+
+func (s importerStats) Stats() importer.Stats {
+	return importer.Stats(s)
+}
+
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	ts := httptest.NewServer(m.Handler())
+	defer ts.Close()
+	res, err := http.Get(ts.URL)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	m := metrics.New()
+	recipesModel := model.NewRecipesModel(model.ObserveLockWait(m.ObserveLockWait))
+	m.WatchModel(recipesModel)
+	m.WatchImporter(importerStats{RowsImported: 3, RowsFailed: 1})
+
+	_, err := recipesModel.Import(strings.NewReader("id,title\n1,Pork Chilli\n2,\n3,Fish Pie"), model.SkipInvalid)
+	require.NoError(t, err)
+	require.NoError(t, recipesModel.RateRecipe(1, &model.RecipeRate{Rate: 5}))
+	m.ObserveRequest("GET", "/recipes/:recipeID", http.StatusOK, 30*time.Millisecond)
+	m.ObserveRequest("GET", "/recipes/:recipeID", http.StatusOK, 3*time.Second)
+
+	body := scrape(t, m)
+	for _, line := range []string{
+		"recipes_model_recipes 2",
+		"recipes_model_rates 1",
+		"recipes_model_events_pending 3",
+		"recipes_model_events_parked_total 0",
+		"recipes_model_events_evicted_total 0",
+		`recipes_import_rows_total{outcome="imported",source="data_source"} 2`,
+		`recipes_import_rows_total{outcome="failed",source="data_source"} 1`,
+		`recipes_import_rows_total{outcome="imported",source="upload"} 3`,
+		`recipes_import_rows_total{outcome="failed",source="upload"} 1`,
+		`recipes_http_requests_total{method="GET",route="/recipes/:recipeID",status="200"} 2`,
+		`recipes_http_request_duration_seconds_bucket{method="GET",route="/recipes/:recipeID",status="200",le="0.05"} 1`,
+		`recipes_http_request_duration_seconds_count{method="GET",route="/recipes/:recipeID",status="200"} 2`,
+		//Import, RateRecipe and Stats of the scrape
+		"recipes_model_lock_wait_seconds_count 3",
+	} {
+		assert.Contains(t, body, line+"\n")
+	}
+	assert.Contains(t, body, "go_goroutines ")
+}
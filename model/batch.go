@@ -0,0 +1,173 @@
+package model
+
+import "github.com/pkg/errors"
+
+var BatchAbortedError = errors.New("Batch aborted, no changes applied")
+var MissingIDError = errors.New("Recipe id is required")
+
+type BatchOperationType string
+
+const (
+	BatchCreate BatchOperationType = "create"
+	BatchUpdate BatchOperationType = "update"
+	BatchDelete BatchOperationType = "delete"
+)
+
+//BatchOperation is a single change of a batch, ID is the recipe it applies to and overrides Recipe.Id.
+//Recipe is ignored by delete.
+type BatchOperation struct {
+	Op     BatchOperationType `json:"op" xml:"op"`
+	ID     int                `json:"id" xml:"id"`
+	Recipe *Recipe            `json:"recipe,omitempty" xml:"recipe,omitempty"`
+}
+
+type BatchStatus string
+
+const (
+	BatchApplied    BatchStatus = "applied"
+	BatchFailed     BatchStatus = "failed"
+	BatchRolledBack BatchStatus = "rolled_back"
+)
+
+//BatchResult tells what happened with operation of the same index
+type BatchResult struct {
+	Op     BatchOperationType `json:"op" xml:"op"`
+	ID     int                `json:"id" xml:"id"`
+	Status BatchStatus        `json:"status" xml:"status"`
+	Error  string             `json:"error,omitempty" xml:"error,omitempty"`
+	//Violations are set when operation failed on validation
+	Violations []Violation `json:"violations,omitempty" xml:"violations>violation,omitempty"`
+}
+
+type RecipesBatchWriter interface {
+	ApplyBatch(operations []BatchOperation) ([]BatchResult, error)
+}
+
+//ApplyBatch applies all operations or none of them. Every operation is tried, even after a failure, so caller gets
+//all problems at once; if any failed the model is put back the way it was and BatchAbortedError is returned.
+//Operations see effects of earlier ones, e.g. recipe can be created and updated in the same batch.
+func (r *RecipesModel) ApplyBatch(operations []BatchOperation) ([]BatchResult, error) {
+	r.lock()
+	defer r.mx.Unlock()
+	snapshot := r.snapshot()
+	results := make([]BatchResult, len(operations))
+	events := make([]Event, 0, len(operations))
+	failed := false
+	for i, operation := range operations {
+		results[i] = BatchResult{Op: operation.Op, ID: operation.ID, Status: BatchApplied}
+		if err := r.applyBatchOperation(operation); err != nil {
+			failed = true
+			results[i].Status = BatchFailed
+			results[i].Error = err.Error()
+			if validationErr, ok := err.(*ValidationError); ok {
+				results[i].Violations = validationErr.Violations
+			}
+		} else if !failed {
+			events = append(events, newEvent(batchEvents[operation.Op], operation.ID, r.recipes[operation.ID]))
+		}
+	}
+	if !failed {
+		r.publish(events...)
+		return results, nil
+	}
+	r.restore(snapshot)
+	for i := range results {
+		if results[i].Status == BatchApplied {
+			results[i].Status = BatchRolledBack
+		}
+	}
+	return results, BatchAbortedError
+}
+
+//batchEvents tells what event applied operation is published as
+var batchEvents = map[BatchOperationType]EventType{
+	BatchCreate: RecipeCreated,
+	BatchUpdate: RecipeUpdated,
+	BatchDelete: RecipeDeleted,
+}
+
+//Must be called with lock held.
+func (r *RecipesModel) applyBatchOperation(operation BatchOperation) error {
+	if operation.ID == 0 {
+		return MissingIDError
+	}
+	switch operation.Op {
+	case BatchCreate, BatchUpdate:
+		if operation.Recipe == nil {
+			return errors.Errorf("Recipe is required for %s", operation.Op)
+		}
+		//batch keeps its own copy, so rolled back operation doesn't leave recipe pointer in caller's hands
+		recipe := *operation.Recipe
+		recipe.Id = operation.ID
+		if err := recipe.Validate(); err != nil {
+			return err
+		}
+		if operation.Op == BatchCreate {
+			return r.createRecipe(&recipe)
+		}
+		return r.updateRecipe(operation.ID, &recipe)
+	case BatchDelete:
+		return r.deleteRecipe(operation.ID)
+	}
+	return errors.Errorf("Unknown operation: %s", operation.Op)
+}
+
+//deleteRecipe drops recipe together with its slug, old slugs and gousto reference.
+//Must be called with lock held.
+func (r *RecipesModel) deleteRecipe(recipeID int) error {
+	recipe, ok := r.recipes[recipeID]
+	if !ok {
+		return NotFoundError
+	}
+	delete(r.slugs, recipe.Slug)
+	for slug, id := range r.slugRedirects {
+		if id == recipeID {
+			delete(r.slugRedirects, slug)
+		}
+	}
+	r.unindexGoustoReference(recipeID, recipe.GoustoReference)
+	delete(r.recipes, recipeID)
+	return nil
+}
+
+//modelState is everything batch can change. Recipes are replaced rather than modified, so copying maps is enough.
+type modelState struct {
+	recipes          map[int]*Recipe
+	slugs            map[string]int
+	slugRedirects    map[string]int
+	goustoReferences map[int]map[int]struct{}
+}
+
+//Must be called with lock held.
+func (r *RecipesModel) snapshot() modelState {
+	s := modelState{
+		recipes:          make(map[int]*Recipe, len(r.recipes)),
+		slugs:            make(map[string]int, len(r.slugs)),
+		slugRedirects:    make(map[string]int, len(r.slugRedirects)),
+		goustoReferences: make(map[int]map[int]struct{}, len(r.goustoReferences)),
+	}
+	for k, v := range r.recipes {
+		s.recipes[k] = v
+	}
+	for k, v := range r.slugs {
+		s.slugs[k] = v
+	}
+	for k, v := range r.slugRedirects {
+		s.slugRedirects[k] = v
+	}
+	for reference, ids := range r.goustoReferences {
+		s.goustoReferences[reference] = make(map[int]struct{}, len(ids))
+		for id := range ids {
+			s.goustoReferences[reference][id] = struct{}{}
+		}
+	}
+	return s
+}
+
+//Must be called with lock held.
+func (r *RecipesModel) restore(s modelState) {
+	r.recipes = s.recipes
+	r.slugs = s.slugs
+	r.slugRedirects = s.slugRedirects
+	r.goustoReferences = s.goustoReferences
+}
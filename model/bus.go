@@ -0,0 +1,257 @@
+package model
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	redeliveryBackoff    = 100 * time.Millisecond
+	maxRedeliveryBackoff = 30 * time.Second
+
+	//DefaultDeliveryAttempts gives subscriber about a minute to recover
+	DefaultDeliveryAttempts = 10
+	//DefaultOutboxRetention is how many undelivered events outbox keeps at most
+	DefaultOutboxRetention = 10000
+)
+
+//Subscriber gets events in the order they happened, one at a time. Failed event is given again until it's handled
+//or runs out of attempts and is parked, events after it wait, so Handle should be idempotent and report only errors
+//worth repeating.
+type Subscriber interface {
+	Handle(event Event) error
+}
+
+type SubscriberFunc func(event Event) error
+
+func (f SubscriberFunc) Handle(event Event) error {
+	return f(event)
+}
+
+type RecipesEventBus interface {
+	//Subscribe delivers events of given types, all of them when none given, published from now on. When nobody
+	//was subscribed, the events kept meanwhile are delivered first. Unsubscribe waits for Handle in progress, so it must not be called from it.
+	Subscribe(subscriber Subscriber, types ...EventType) (unsubscribe func())
+}
+
+//outbox keeps events until every subscriber handled them, or until a subscriber comes when there is none. Model
+//writes to it in the same critical section as it changes recipes, so an event exists if and only if its change
+//does; relays deliver them afterwards. Like recipes, outbox lives in memory only and doesn't survive a restart.
+//Oldest events are evicted beyond retention, so a lagging subscriber misses them rather than grow the outbox.
+type outbox struct {
+	mx      sync.Mutex
+	events  []Event
+	lastID  int64
+	relays  map[*relay]struct{}
+	parked  int
+	evicted int
+
+	//attempts, onParked and retention are set by options, before any relay runs
+	attempts  int
+	onParked  func(event Event, err error)
+	retention int
+}
+
+func newOutbox() *outbox {
+	return &outbox{relays: make(map[*relay]struct{}), attempts: DefaultDeliveryAttempts, retention: DefaultOutboxRetention}
+}
+
+//DeliveryAttempts is how many times subscriber gets an event before it's parked, so a broken subscriber can't hold
+//the outbox and every later event for good. DefaultDeliveryAttempts is used otherwise.
+func DeliveryAttempts(attempts int) Option {
+	return func(r *RecipesModel) {
+		r.outbox.attempts = attempts
+	}
+}
+
+//OnParked is told about events a subscriber ran out of attempts for, e.g. to log them. It's called from the relay of
+//the subscriber, without model lock held.
+func OnParked(park func(event Event, err error)) Option {
+	return func(r *RecipesModel) {
+		r.outbox.onParked = park
+	}
+}
+
+//OutboxRetention is how many events outbox keeps for subscribers at most, DefaultOutboxRetention is used otherwise
+func OutboxRetention(events int) Option {
+	return func(r *RecipesModel) {
+		r.outbox.retention = events
+	}
+}
+
+//append gives events their IDs, returned events have them set
+func (o *outbox) append(events []Event) []Event {
+	o.mx.Lock()
+	defer o.mx.Unlock()
+	for i := range events {
+		o.lastID++
+		events[i].ID = o.lastID
+	}
+	o.events = append(o.events, events...)
+	if over := len(o.events) - o.retention; over > 0 {
+		o.evicted += over
+		o.events = append(o.events[:0], o.events[over:]...)
+	}
+	for relay := range o.relays {
+		select {
+		case relay.wake <- struct{}{}:
+		default:
+		}
+	}
+	return events
+}
+
+//pending is what relay hasn't handled yet
+func (o *outbox) pending(relay *relay) []Event {
+	o.mx.Lock()
+	defer o.mx.Unlock()
+	for i, event := range o.events {
+		if event.ID > relay.cursor {
+			return append([]Event{}, o.events[i:]...)
+		}
+	}
+	return nil
+}
+
+//ack moves relay past the event and drops events every relay is past
+func (o *outbox) ack(relay *relay, id int64) {
+	o.mx.Lock()
+	defer o.mx.Unlock()
+	relay.cursor = id
+	o.compact()
+}
+
+//park counts the event relay gave up on, it's acked afterwards as if handled
+func (o *outbox) park(event Event, err error) {
+	o.mx.Lock()
+	o.parked++
+	o.mx.Unlock()
+	if o.onParked != nil {
+		o.onParked(event, err)
+	}
+}
+
+//stats are events not handled yet, events parked and events evicted so far
+func (o *outbox) stats() (pending int, parked int, evicted int) {
+	o.mx.Lock()
+	defer o.mx.Unlock()
+	return len(o.events), o.parked, o.evicted
+}
+
+//compact keeps everything when there is no relay, next subscriber gets it.
+//Must be called with lock held.
+func (o *outbox) compact() {
+	if len(o.relays) == 0 {
+		return
+	}
+	oldest := o.lastID
+	for relay := range o.relays {
+		if relay.cursor < oldest {
+			oldest = relay.cursor
+		}
+	}
+	i := 0
+	for i < len(o.events) && o.events[i].ID <= oldest {
+		i++
+	}
+	o.events = append(o.events[:0], o.events[i:]...)
+}
+
+func (o *outbox) subscribe(subscriber Subscriber, types []EventType) func() {
+	o.mx.Lock()
+	defer o.mx.Unlock()
+	r := &relay{
+		subscriber: subscriber,
+		types:      make(map[EventType]bool, len(types)),
+		cursor:     o.lastID,
+		wake:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	if len(o.relays) == 0 && len(o.events) > 0 {
+		r.cursor = o.events[0].ID - 1
+	}
+	for _, t := range types {
+		r.types[t] = true
+	}
+	o.relays[r] = struct{}{}
+	go r.run(o)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(r.done)
+			<-r.stopped
+			o.mx.Lock()
+			defer o.mx.Unlock()
+			delete(o.relays, r)
+			o.compact()
+		})
+	}
+}
+
+//relay delivers outbox events to single subscriber
+type relay struct {
+	subscriber Subscriber
+	types      map[EventType]bool
+	//cursor is the last event handled, guarded by outbox lock
+	cursor  int64
+	wake    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func (r *relay) run(o *outbox) {
+	defer close(r.stopped)
+	for {
+		for _, event := range o.pending(r) {
+			if !r.deliver(o, event) {
+				return
+			}
+			o.ack(r, event.ID)
+		}
+		select {
+		case <-r.wake:
+		case <-r.done:
+			return
+		}
+	}
+}
+
+//deliver repeats failed event with growing backoff until it runs out of attempts, false means relay was stopped
+//in the meantime
+func (r *relay) deliver(o *outbox, event Event) bool {
+	if len(r.types) > 0 && !r.types[event.Type] {
+		return true
+	}
+	backoff := redeliveryBackoff
+	for attempt := 1; ; attempt++ {
+		err := r.subscriber.Handle(event)
+		if err == nil {
+			return true
+		}
+		if attempt >= o.attempts {
+			o.park(event, err)
+			return true
+		}
+		select {
+		case <-r.done:
+			return false
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxRedeliveryBackoff {
+			backoff = maxRedeliveryBackoff
+		}
+	}
+}
+
+//Subscribe is the way for side effects, like webhooks or search index, to follow recipe changes
+func (r *RecipesModel) Subscribe(subscriber Subscriber, types ...EventType) func() {
+	return r.outbox.subscribe(subscriber, types)
+}
+
+//publish puts events in the outbox and the feed of SubscribeEvents.
+//Must be called with lock held, right after the change events describe.
+func (r *RecipesModel) publish(events ...Event) {
+	r.events.publish(r.outbox.append(events)...)
+}
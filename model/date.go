@@ -1,14 +1,22 @@
 package model
 
 import (
-	"fmt"
+	"encoding/json"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
-//DateTime structure is specific for unmarshaling given CSV as it doesn't follow any standard format
+//CSVLayout is the layout of dates in recipe-data.csv
+const CSVLayout = "02/01/2006 15:04:05"
+
+//CSVLocation is the zone of dates written in CSVLayout as the layout itself has none
+var CSVLocation = time.UTC
+
+//DateTime structure is specific for unmarshaling given CSV as it doesn't follow any standard format.
+//Input can be either RFC 3339 or CSVLayout, empty value (or JSON null) is zero time.
+//Output is RFC 3339 in UTC everywhere apart from CSV, which keeps CSVLayout in CSVLocation so files can be re-imported.
 type DateTime struct {
 	time.Time
 }
@@ -18,17 +26,59 @@ func (date *DateTime) UnmarshalCSV(csvTime string) (err error) {
 	return date.Unmarshal(csvTime)
 }
 
-// Convert the CSV string as internal date
+// Convert internal date to the same CSV string it was read from
+func (date DateTime) MarshalCSV() (string, error) {
+	if date.IsZero() {
+		return "", nil
+	}
+	return date.In(CSVLocation).Format(CSVLayout), nil
+}
+
+// Convert the JSON string or null as internal date
 func (date *DateTime) UnmarshalJSON(jsonTime []byte) (err error) {
-	//You have to trim first " and last "
-	s := fmt.Sprintf("%s", jsonTime)
-	return date.Unmarshal(strings.TrimLeft(strings.TrimRight(s, `"`), `"`))
+	if string(jsonTime) == "null" {
+		date.Time = time.Time{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(jsonTime, &s); err != nil {
+		return errors.Wrapf(err, "Can't unmarshal time: %s to DateTime", jsonTime)
+	}
+	return date.Unmarshal(s)
+}
+
+// Convert internal date to RFC 3339 JSON string, zero date is null
+func (date DateTime) MarshalJSON() ([]byte, error) {
+	if date.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(date.UTC().Format(time.RFC3339))
+}
+
+// UnmarshalText is what XML uses, it accepts the same input as JSON
+func (date *DateTime) UnmarshalText(text []byte) error {
+	return date.Unmarshal(string(text))
+}
+
+// MarshalText is what XML uses, zero date is empty
+func (date DateTime) MarshalText() ([]byte, error) {
+	if date.IsZero() {
+		return []byte{}, nil
+	}
+	return []byte(date.UTC().Format(time.RFC3339)), nil
 }
 
 func (date *DateTime) Unmarshal(timeToUnmarshal string) (err error) {
-	date.Time, err = time.Parse("02/01/2006 15:04:05", timeToUnmarshal)
-	if err != nil {
-		return errors.Wrapf(err, "Can't unmarshal time: %s to DateTime", timeToUnmarshal)
+	timeToUnmarshal = strings.TrimSpace(timeToUnmarshal)
+	if timeToUnmarshal == "" {
+		date.Time = time.Time{}
+		return nil
+	}
+	if date.Time, err = time.Parse(time.RFC3339, timeToUnmarshal); err == nil {
+		return nil
+	}
+	if date.Time, err = time.ParseInLocation(CSVLayout, timeToUnmarshal, CSVLocation); err == nil {
+		return nil
 	}
-	return nil
+	return errors.Errorf("Can't unmarshal time: %s to DateTime, expected RFC 3339 or %s", timeToUnmarshal, CSVLayout)
 }
@@ -0,0 +1,149 @@
+package model
+
+import (
+	"sync"
+	"time"
+)
+
+//DefaultEventsCapacity is how many recent events are kept for subscribers resuming after disconnect
+const DefaultEventsCapacity = 1000
+
+//subscriberBuffer is how far subscriber can fall behind before it's dropped
+const subscriberBuffer = 64
+
+type EventType string
+
+const (
+	RecipeCreated EventType = "created"
+	RecipeUpdated EventType = "updated"
+	RecipeDeleted EventType = "deleted"
+	RecipeRated   EventType = "rated"
+)
+
+//Event is a change of a single recipe. IDs start from 1 and grow by one with every event, so a gap means events were
+//lost. Recipe is a copy of the recipe right after the change, it's nil for deleted ones.
+type Event struct {
+	ID         int64     `json:"id"`
+	Type       EventType `json:"type"`
+	RecipeID   int       `json:"recipe_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Recipe     *Recipe   `json:"recipe,omitempty"`
+}
+
+func newEvent(eventType EventType, recipeID int, recipe *Recipe) Event {
+	event := Event{Type: eventType, RecipeID: recipeID, OccurredAt: time.Now().UTC()}
+	if recipe != nil {
+		snapshot := *recipe
+		event.Recipe = &snapshot
+	}
+	return event
+}
+
+type RecipesEventsSubscriber interface {
+	SubscribeEvents(lastEventID int64) *Subscription
+}
+
+//Subscription delivers events after the one subscriber has seen. Backlog is what's already happened, Events what
+//happens next. Events is closed when subscriber falls behind, it should resubscribe with the last ID it got then.
+type Subscription struct {
+	Backlog []Event
+	//Lost is true when some events after lastEventID are no longer kept, subscriber has to resync then
+	Lost   bool
+	Events <-chan Event
+	cancel func()
+}
+
+//Close stops delivery, it's safe to call it more than once
+func (s *Subscription) Close() {
+	s.cancel()
+}
+
+//eventLog is ring buffer of recent events, fanning new ones out to subscribers
+type eventLog struct {
+	mx          sync.Mutex
+	events      []Event
+	start       int
+	lastID      int64
+	subscribers map[chan Event]struct{}
+}
+
+func newEventLog(capacity int) *eventLog {
+	return &eventLog{
+		events:      make([]Event, 0, capacity),
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+//publish takes events numbered by the outbox
+func (l *eventLog) publish(events ...Event) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	for _, event := range events {
+		l.lastID = event.ID
+		switch {
+		case len(l.events) < cap(l.events):
+			l.events = append(l.events, event)
+		case len(l.events) > 0:
+			l.events[l.start] = event
+			l.start = (l.start + 1) % len(l.events)
+		}
+		for subscriber := range l.subscribers {
+			select {
+			case subscriber <- event:
+			default:
+				delete(l.subscribers, subscriber)
+				close(subscriber)
+			}
+		}
+	}
+}
+
+func (l *eventLog) subscribe(lastEventID int64) *Subscription {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	subscription := &Subscription{Backlog: []Event{}}
+	//IDs start over with the process, so the one from before restart says nothing
+	if lastEventID > l.lastID {
+		lastEventID = 0
+		subscription.Lost = true
+	}
+	for i := range l.events {
+		event := l.events[(l.start+i)%len(l.events)]
+		if event.ID > lastEventID {
+			subscription.Backlog = append(subscription.Backlog, event)
+		}
+	}
+	oldestID := l.lastID + 1
+	if len(subscription.Backlog) > 0 {
+		oldestID = subscription.Backlog[0].ID
+	}
+	subscription.Lost = subscription.Lost || lastEventID < oldestID-1
+
+	events := make(chan Event, subscriberBuffer)
+	l.subscribers[events] = struct{}{}
+	subscription.Events = events
+	var once sync.Once
+	subscription.cancel = func() {
+		once.Do(func() {
+			l.mx.Lock()
+			defer l.mx.Unlock()
+			if _, ok := l.subscribers[events]; ok {
+				delete(l.subscribers, events)
+				close(events)
+			}
+		})
+	}
+	return subscription
+}
+
+//EventsCapacity sets how many recent events are kept, DefaultEventsCapacity is used otherwise
+func EventsCapacity(capacity int) Option {
+	return func(r *RecipesModel) {
+		r.events = newEventLog(capacity)
+	}
+}
+
+//SubscribeEvents with zero lastEventID starts from the oldest kept event
+func (r *RecipesModel) SubscribeEvents(lastEventID int64) *Subscription {
+	return r.events.subscribe(lastEventID)
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"encoding/csv"
+	"io"
+	"reflect"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+//csvMarshaler is counterpart of csvUnmarshaler, DateTime implements it
+type csvMarshaler interface {
+	MarshalCSV() (string, error)
+}
+
+//CSVEncoder writes recipes in the same shape LoadFromCSV reads them - canonical header and CSVLayout dates
+type CSVEncoder struct {
+	writer *csv.Writer
+	fields []int
+}
+
+//NewCSVEncoder writes the header straight away
+func NewCSVEncoder(w io.Writer) (*CSVEncoder, error) {
+	e := &CSVEncoder{writer: csv.NewWriter(w)}
+	var header []string
+	t := reflect.TypeOf(Recipe{})
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("csv"); tag != "" && tag != "-" {
+			header = append(header, tag)
+			e.fields = append(e.fields, i)
+		}
+	}
+	if err := e.writer.Write(header); err != nil {
+		return nil, errors.Wrap(err, "failed to write csv header")
+	}
+	return e, nil
+}
+
+func (e *CSVEncoder) Encode(recipe *Recipe) error {
+	v := reflect.ValueOf(recipe).Elem()
+	record := make([]string, len(e.fields))
+	for i, field := range e.fields {
+		value, err := formatField(v.Field(field))
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode recipe %d", recipe.Id)
+		}
+		record[i] = value
+	}
+	return errors.Wrap(e.writer.Write(record), "failed to write csv")
+}
+
+//Flush has to be called at the end, it's also fine to call it in between to push rows out
+func (e *CSVEncoder) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func formatField(field reflect.Value) (string, error) {
+	if m, ok := field.Interface().(csvMarshaler); ok {
+		return m.MarshalCSV()
+	}
+	switch field.Kind() {
+	case reflect.String:
+		return field.String(), nil
+	case reflect.Int:
+		return strconv.FormatInt(field.Int(), 10), nil
+	}
+	return "", errors.Errorf("unsupported field type %s", field.Type())
+}
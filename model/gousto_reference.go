@@ -0,0 +1,78 @@
+package model
+
+import "sort"
+
+//UniqueGoustoReference turns on uniqueness of GoustoReference. It's off by default as given CSV already has duplicates.
+//Zero reference is treated as "not set" and never clashes.
+func UniqueGoustoReference() Option {
+	return func(r *RecipesModel) {
+		r.uniqueGoustoReference = true
+	}
+}
+
+//FetchByGoustoReference returns all recipes with given reference sorted by id, without uniqueness it can be more than one
+func (r *RecipesModel) FetchByGoustoReference(reference int) []*Recipe {
+	r.lock()
+	defer r.mx.Unlock()
+	v := []*Recipe{}
+	for _, id := range r.goustoReferenceIDs(reference) {
+		v = append(v, r.recipes[id])
+	}
+	return v
+}
+
+//GoustoReferenceConflicts reports every reference shared by more than one recipe together with ids of these recipes
+func (r *RecipesModel) GoustoReferenceConflicts() map[int][]int {
+	r.lock()
+	defer r.mx.Unlock()
+	conflicts := make(map[int][]int)
+	for reference, ids := range r.goustoReferences {
+		if len(ids) > 1 {
+			conflicts[reference] = r.goustoReferenceIDs(reference)
+		}
+	}
+	return conflicts
+}
+
+//Must be called with lock held.
+func (r *RecipesModel) goustoReferenceIDs(reference int) []int {
+	var ids []int
+	for id := range r.goustoReferences[reference] {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+//goustoReferenceTaken tells if reference is used by any recipe other than given one, always false when uniqueness is off.
+//Must be called with lock held.
+func (r *RecipesModel) goustoReferenceTaken(reference int, recipeID int) bool {
+	if !r.uniqueGoustoReference || reference == 0 {
+		return false
+	}
+	for id := range r.goustoReferences[reference] {
+		if id != recipeID {
+			return true
+		}
+	}
+	return false
+}
+
+//Must be called with lock held.
+func (r *RecipesModel) indexGoustoReference(recipeID int, reference int) {
+	if reference == 0 {
+		return
+	}
+	if _, ok := r.goustoReferences[reference]; !ok {
+		r.goustoReferences[reference] = make(map[int]struct{})
+	}
+	r.goustoReferences[reference][recipeID] = struct{}{}
+}
+
+//Must be called with lock held.
+func (r *RecipesModel) unindexGoustoReference(recipeID int, reference int) {
+	delete(r.goustoReferences[reference], recipeID)
+	if len(r.goustoReferences[reference]) == 0 {
+		delete(r.goustoReferences, reference)
+	}
+}
@@ -0,0 +1,253 @@
+package model
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var InvalidImportError = errors.New("Invalid import")
+
+//ImportMode decides what happens with invalid rows
+type ImportMode int
+
+const (
+	//FailFast stops on first invalid row and imports nothing
+	FailFast ImportMode = iota
+	//SkipInvalid imports all valid rows and reports invalid ones
+	SkipInvalid
+	//DryRun validates everything, reports all errors and imports nothing
+	DryRun
+)
+
+func (m ImportMode) String() string {
+	switch m {
+	case FailFast:
+		return "fail_fast"
+	case SkipInvalid:
+		return "skip_invalid"
+	case DryRun:
+		return "dry_run"
+	}
+	return fmt.Sprintf("unknown(%d)", int(m))
+}
+
+//ParseImportMode is reverse of ImportMode.String
+func ParseImportMode(mode string) (ImportMode, error) {
+	for _, m := range []ImportMode{FailFast, SkipInvalid, DryRun} {
+		if m.String() == mode {
+			return m, nil
+		}
+	}
+	return FailFast, errors.Errorf("unknown import mode: %s", mode)
+}
+
+//RowError describes single problem found in imported CSV.
+//Line is CSV record number where header is line 1 - the same as file line as long as no value spans multiple lines.
+//Column is empty when whole row is broken.
+type RowError struct {
+	Line   int    `json:"line"`
+	Column string `json:"column,omitempty"`
+	Reason string `json:"reason"`
+}
+
+func (e RowError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+	}
+	return fmt.Sprintf("line %d, column %s: %s", e.Line, e.Column, e.Reason)
+}
+
+//ImportReport is machine readable summary of import, it's meant to be marshaled as JSON
+type ImportReport struct {
+	Mode         string     `json:"mode"`
+	RowsRead     int        `json:"rows_read"`
+	RowsValid    int        `json:"rows_valid"`
+	RowsImported int        `json:"rows_imported"`
+	RowsSkipped  int        `json:"rows_skipped"`
+	Errors       []RowError `json:"errors"`
+}
+
+//csvUnmarshaler is the same contract as the one gocsv uses, DateTime implements it
+type csvUnmarshaler interface {
+	UnmarshalCSV(string) error
+}
+
+//CSVDecoder reads recipes row by row using model column mapping, Import is built on top of it
+type CSVDecoder struct {
+	reader  *csv.Reader
+	columns []int
+	line    int
+}
+
+//NewCSVDecoder is NewCSVDecoderWithMapping using model column mapping
+func (r *RecipesModel) NewCSVDecoder(in io.Reader) (*CSVDecoder, []RowError, error) {
+	return NewCSVDecoderWithMapping(in, r.columnMapping)
+}
+
+//NewCSVDecoderWithMapping reads and maps the header. Header problems come back as row errors of line 1 together with
+//InvalidImportError, unreadable header as plain error.
+func NewCSVDecoderWithMapping(in io.Reader, mapping ColumnMapping) (*CSVDecoder, []RowError, error) {
+	reader := csv.NewReader(in)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to read csv header")
+	}
+	columns, headerErrors := mapping.columns(header)
+	if len(headerErrors) > 0 {
+		return nil, headerErrors, errors.Wrap(InvalidImportError, headerErrors[0].Error())
+	}
+	return &CSVDecoder{reader: reader, columns: columns, line: 1}, nil, nil
+}
+
+//Next returns next recipe or errors of the row, recipe is nil when row is invalid.
+//io.EOF is returned at the end, any other error means CSV can't be read any further.
+func (d *CSVDecoder) Next() (*Recipe, []RowError, error) {
+	record, err := d.reader.Read()
+	if err == io.EOF {
+		return nil, nil, err
+	}
+	d.line++
+	if err != nil {
+		//broken quoting etc. - encoding/csv can carry on with next record
+		if _, ok := err.(*csv.ParseError); !ok {
+			return nil, nil, errors.Wrap(err, "failed to read csv")
+		}
+		return nil, []RowError{{Line: d.line, Reason: err.Error()}}, nil
+	}
+	recipe, rowErrors := decodeRecipe(d.line, d.columns, record)
+	if len(rowErrors) > 0 {
+		return nil, rowErrors, nil
+	}
+	return recipe, nil, nil
+}
+
+//Line of the row returned by last Next call
+func (d *CSVDecoder) Line() int {
+	return d.line
+}
+
+//Import is strict version of LoadFromCSV. It validates each row separately and reports every problem it finds.
+//Error is returned only when import was aborted - in FailFast mode on first invalid row or when CSV itself is unreadable.
+func (r *RecipesModel) Import(in io.Reader, mode ImportMode) (*ImportReport, error) {
+	report := &ImportReport{Mode: mode.String(), Errors: []RowError{}}
+	decoder, headerErrors, err := r.NewCSVDecoder(in)
+	if err != nil {
+		report.Errors = append(report.Errors, headerErrors...)
+		return report, err
+	}
+
+	var valid []*Recipe
+	var lines []int
+	//idLines is where each id was first used, later rows with the same id are errors rather than overwrites
+	idLines := make(map[int]int)
+	for {
+		recipe, rowErrors, err := decoder.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return report, err
+		}
+		report.RowsRead++
+		if recipe != nil {
+			if err := recipe.Validate(); err != nil {
+				rowErrors = err.(*ValidationError).csvRowErrors(decoder.Line())
+				recipe = nil
+			}
+		}
+		if recipe != nil {
+			if line, ok := idLines[recipe.Id]; ok {
+				rowErrors = []RowError{{Line: decoder.Line(), Column: "id", Reason: fmt.Sprintf("id %d already used on line %d", recipe.Id, line)}}
+				recipe = nil
+			} else {
+				idLines[recipe.Id] = decoder.Line()
+			}
+		}
+		if recipe != nil {
+			valid = append(valid, recipe)
+			lines = append(lines, decoder.Line())
+		}
+		report.Errors = append(report.Errors, rowErrors...)
+		if mode == FailFast && len(report.Errors) > 0 {
+			return report, errors.Wrap(InvalidImportError, report.Errors[0].Error())
+		}
+	}
+
+	r.lock()
+	defer r.mx.Unlock()
+	accepted := make([]*Recipe, 0, len(valid))
+	seen := make(map[int]int)
+	for i, recipe := range valid {
+		if recipe.GoustoReference != 0 && r.uniqueGoustoReference {
+			if id, ok := seen[recipe.GoustoReference]; (ok && id != recipe.Id) || r.goustoReferenceTaken(recipe.GoustoReference, recipe.Id) {
+				report.Errors = append(report.Errors, RowError{Line: lines[i], Column: "gousto_reference", Reason: GoustoReferenceConflictError.Error()})
+				if mode == FailFast {
+					return report, errors.Wrap(InvalidImportError, report.Errors[len(report.Errors)-1].Error())
+				}
+				continue
+			}
+			seen[recipe.GoustoReference] = recipe.Id
+		}
+		accepted = append(accepted, recipe)
+	}
+	report.RowsValid = len(accepted)
+	report.RowsSkipped = report.RowsRead - report.RowsValid
+	if mode != DryRun {
+		r.store(accepted)
+		report.RowsImported = len(accepted)
+		r.rowsImported += report.RowsImported
+		r.rowsSkipped += report.RowsSkipped
+	}
+	return report, nil
+}
+
+func decodeRecipe(line int, columns []int, record []string) (*Recipe, []RowError) {
+	recipe := &Recipe{}
+	var rowErrors []RowError
+	if len(record) != len(columns) {
+		return nil, []RowError{{Line: line, Reason: fmt.Sprintf("expected %d columns, got %d", len(columns), len(record))}}
+	}
+	v := reflect.ValueOf(recipe).Elem()
+	t := v.Type()
+	for i, value := range record {
+		if columns[i] < 0 {
+			continue
+		}
+		column := t.Field(columns[i]).Tag.Get("csv")
+		if err := setField(v.Field(columns[i]), value); err != nil {
+			rowErrors = append(rowErrors, RowError{Line: line, Column: column, Reason: errors.Cause(err).Error()})
+		}
+	}
+	return recipe, rowErrors
+}
+
+func setField(field reflect.Value, value string) error {
+	if u, ok := field.Addr().Interface().(csvUnmarshaler); ok {
+		return u.UnmarshalCSV(value)
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		value = strings.TrimSpace(value)
+		if value == "" {
+			field.SetInt(0)
+			return nil
+		}
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.Errorf("%q is not a valid integer", value)
+		}
+		field.SetInt(int64(i))
+	default:
+		return errors.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+//UnknownColumnPolicy decides what to do with CSV columns which don't map to any Recipe field
+type UnknownColumnPolicy int
+
+const (
+	//RejectUnknownColumns fails the import, that's how typos in header don't get silently dropped
+	RejectUnknownColumns UnknownColumnPolicy = iota
+	//IgnoreUnknownColumns skips such columns
+	IgnoreUnknownColumns
+)
+
+//ColumnMapping describes how CSV header is mapped onto Recipe fields.
+//Canonical column names are the csv tags of Recipe.
+type ColumnMapping struct {
+	//Aliases maps alternative header names onto canonical ones
+	Aliases map[string]string
+	//Required columns have to be present in the header
+	Required       []string
+	UnknownColumns UnknownColumnPolicy
+}
+
+//DefaultColumnMapping is what recipe-data.csv needs. "uploaded_at" alias is there for files exported before
+//the column got its proper name.
+func DefaultColumnMapping() ColumnMapping {
+	return ColumnMapping{
+		Aliases:        map[string]string{"uploaded_at": "updated_at"},
+		Required:       []string{"id", "title"},
+		UnknownColumns: RejectUnknownColumns,
+	}
+}
+
+//WithColumnMapping replaces DefaultColumnMapping used by LoadFromCSV and Import
+func WithColumnMapping(mapping ColumnMapping) Option {
+	return func(r *RecipesModel) {
+		r.columnMapping = mapping
+	}
+}
+
+//ColumnMapping is the mapping LoadFromCSV and Import read csv header with
+func (r *RecipesModel) ColumnMapping() ColumnMapping {
+	return r.columnMapping
+}
+
+//recipeFields indexes Recipe fields by their csv tag
+func recipeFields() map[string]int {
+	fields := make(map[string]int)
+	t := reflect.TypeOf(Recipe{})
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("csv"); tag != "" && tag != "-" {
+			fields[tag] = i
+		}
+	}
+	return fields
+}
+
+//columns maps CSV header to Recipe field indexes, ignored columns are mapped to -1.
+//Every header problem is reported at once as errors of line 1.
+func (m ColumnMapping) columns(header []string) ([]int, []RowError) {
+	fields := recipeFields()
+	for alias, canonical := range m.Aliases {
+		if _, ok := fields[canonical]; !ok {
+			return nil, []RowError{{Line: 1, Column: alias, Reason: fmt.Sprintf("alias of unknown column %s", canonical)}}
+		}
+	}
+
+	var headerErrors []RowError
+	columns := make([]int, len(header))
+	mapped := make(map[string]string)
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		canonical := name
+		if c, ok := m.Aliases[name]; ok {
+			canonical = c
+		}
+		field, ok := fields[canonical]
+		if !ok {
+			columns[i] = -1
+			if m.UnknownColumns == RejectUnknownColumns {
+				headerErrors = append(headerErrors, RowError{Line: 1, Column: name, Reason: "unknown column"})
+			}
+			continue
+		}
+		if previous, ok := mapped[canonical]; ok {
+			columns[i] = -1
+			headerErrors = append(headerErrors, RowError{Line: 1, Column: name, Reason: fmt.Sprintf("duplicates column %s", previous)})
+			continue
+		}
+		mapped[canonical] = name
+		columns[i] = field
+	}
+	for _, required := range m.Required {
+		if _, ok := mapped[required]; !ok {
+			headerErrors = append(headerErrors, RowError{Line: 1, Column: required, Reason: "required column missing"})
+		}
+	}
+	return columns, headerErrors
+}
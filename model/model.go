@@ -1,20 +1,26 @@
 package model
 
 import (
+	"fmt"
 	"io"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
-	"github.com/gocarina/gocsv"
 	"github.com/pkg/errors"
 )
 
 var NotFoundError = errors.New("Not found")
 var DuplicateError = errors.New("Duplicate entry")
+var GoustoReferenceConflictError = errors.New("Gousto reference already used")
 
 type RecipesFetcher interface {
 	FetchOneByID(recipeID int) (*Recipe, error)
+	FetchOneBySlug(slug string) (*Recipe, error)
 	FetchRecipes(limiter *Limiter) []*Recipe
+	FetchByGoustoReference(reference int) []*Recipe
+	FetchManyByIDs(recipeIDs []int) (found []*Recipe, missing []int)
 }
 
 type RecipesCreator interface {
@@ -23,14 +29,30 @@ type RecipesCreator interface {
 
 type RecipesUpdater interface {
 	UpdateRecipe(recipeID int, recipe *Recipe) error
+	UpsertRecipe(recipe *Recipe) error
 }
 
 type RecipesRater interface {
 	RateRecipe(recipeID int, rate *RecipeRate) error
+	FetchRates(recipeID int) ([]*RecipeRate, error)
+}
+
+type RecipesConflictsReporter interface {
+	GoustoReferenceConflicts() map[int][]int
+}
+
+//RecipesColumnMapper tells which csv header the model reads
+type RecipesColumnMapper interface {
+	ColumnMapping() ColumnMapping
 }
 
 type RecipesAggregator interface {
+	RecipesBatchWriter
+	RecipesColumnMapper
+	RecipesConflictsReporter
 	RecipesCreator
+	RecipesEventBus
+	RecipesEventsSubscriber
 	RecipesFetcher
 	RecipesRater
 	RecipesUpdater
@@ -42,35 +64,80 @@ type Limiter struct {
 }
 
 type Recipe struct {
-	Id                     int      `csv:"id" json:"id"`
-	CreatedAt              DateTime `csv:"created_at" json:"created_at"`
-	UploadedAt             DateTime `csv:"uploaded_at" json:"uploaded_at"`
-	BoxType                string   `csv:"box_type" json:"box_type"`
-	Title                  string   `csv:"title" json:"title"`
-	Slug                   string   `csv:"slug" json:"slug"`
-	ShortTitle             string   `csv:"short_title" json:"short_title"`
-	MarketingDescription   string   `csv:"marketing_description" json:"marketing_description"`
-	CaloriesKCal           int      `csv:"calories_kcal" json:"calories_k_cal"`
-	ProteinGrams           int      `csv:"protein_grams" json:"protein_grams"`
-	FatGrams               int      `csv:"fat_grams" json:"fat_grams"`
-	CarbsGrams             int      `csv:"carbs_grams" json:"carbs_grams"`
-	Bulletpoint1           string   `csv:"bulletpoint1" json:"bulletpoint_1"`
-	Bulletpoint2           string   `csv:"bulletpoint2" json:"bulletpoint_2"`
-	Bulletpoint3           string   `csv:"bulletpoint3" json:"bulletpoint_3"`
-	RecipeDietTypeId       string   `csv:"recipe_diet_type_id" json:"recipe_diet_type_id"`
-	Season                 string   `csv:"season" json:"season"`
-	Base                   string   `csv:"base" json:"base"`
-	ProteinSource          string   `csv:"protein_source" json:"protein_source"`
-	PreparationTimeMinutes int      `csv:"preparation_time_minutes" json:"preparation_time_minutes"` //In ideal world this would be time.Duration
-	ShelfLifeDays          int      `csv:"shelf_life_days" json:"shelf_life_days"`                   //Same - time.Duration
-	EquipmentNeeded        string   `csv:"equipment_needed" json:"equipment_needed"`                 //This could be slice of equipment or strings
-	OriginCountry          string   `csv:"origin_country" json:"origin_country"`
-	RecipeCuisine          string   `csv:"recipe_cuisine" json:"recipe_cuisine"`
-	InYourBox              string   `csv:"in_your_box" json:"in_your_box"` //Same this is a great example of slice of strings
-	GoustoReference        int      `csv:"gousto_reference" json:"gousto_reference"`
+	Id                     int      `csv:"id" json:"id" xml:"id"`
+	CreatedAt              DateTime `csv:"created_at" json:"created_at" xml:"created_at"`
+	UpdatedAt              DateTime `csv:"updated_at" json:"uploaded_at" xml:"uploaded_at"` //json name kept for existing clients
+	BoxType                string   `csv:"box_type" json:"box_type" xml:"box_type" validate:"oneof=gourmet vegetarian"`
+	Title                  string   `csv:"title" json:"title" xml:"title" validate:"required,max=255"`
+	Slug                   string   `csv:"slug" json:"slug" xml:"slug" validate:"max=255"`
+	ShortTitle             string   `csv:"short_title" json:"short_title" xml:"short_title" validate:"max=255"`
+	MarketingDescription   string   `csv:"marketing_description" json:"marketing_description" xml:"marketing_description" validate:"max=1000"`
+	CaloriesKCal           int      `csv:"calories_kcal" json:"calories_k_cal" xml:"calories_k_cal" validate:"min=0"`
+	ProteinGrams           int      `csv:"protein_grams" json:"protein_grams" xml:"protein_grams" validate:"min=0"`
+	FatGrams               int      `csv:"fat_grams" json:"fat_grams" xml:"fat_grams" validate:"min=0"`
+	CarbsGrams             int      `csv:"carbs_grams" json:"carbs_grams" xml:"carbs_grams" validate:"min=0"`
+	Bulletpoint1           string   `csv:"bulletpoint1" json:"bulletpoint_1" xml:"bulletpoint_1" validate:"max=255"`
+	Bulletpoint2           string   `csv:"bulletpoint2" json:"bulletpoint_2" xml:"bulletpoint_2" validate:"max=255"`
+	Bulletpoint3           string   `csv:"bulletpoint3" json:"bulletpoint_3" xml:"bulletpoint_3" validate:"max=255"`
+	RecipeDietTypeId       string   `csv:"recipe_diet_type_id" json:"recipe_diet_type_id" xml:"recipe_diet_type_id"`
+	Season                 string   `csv:"season" json:"season" xml:"season"`
+	Base                   string   `csv:"base" json:"base" xml:"base"`
+	ProteinSource          string   `csv:"protein_source" json:"protein_source" xml:"protein_source"`
+	PreparationTimeMinutes int      `csv:"preparation_time_minutes" json:"preparation_time_minutes" xml:"preparation_time_minutes" validate:"min=0,max=600"` //In ideal world this would be time.Duration
+	ShelfLifeDays          int      `csv:"shelf_life_days" json:"shelf_life_days" xml:"shelf_life_days" validate:"min=0,max=365"`                            //Same - time.Duration
+	EquipmentNeeded        string   `csv:"equipment_needed" json:"equipment_needed" xml:"equipment_needed" validate:"max=255"`                               //This could be slice of equipment or strings
+	OriginCountry          string   `csv:"origin_country" json:"origin_country" xml:"origin_country"`
+	RecipeCuisine          string   `csv:"recipe_cuisine" json:"recipe_cuisine" xml:"recipe_cuisine" validate:"oneof=asian british italian mediterranean mexican"`
+	InYourBox              string   `csv:"in_your_box" json:"in_your_box" xml:"in_your_box" validate:"max=1000"` //Same this is a great example of slice of strings
+	GoustoReference        int      `csv:"gousto_reference" json:"gousto_reference" xml:"gousto_reference"`
 
 	rates       []*RecipeRate
-	AverageRate float32
+	AverageRate float32 //no tags, name kept for existing clients
+}
+
+//MaxBulletpoints is how many of them Recipe has room for
+const MaxBulletpoints = 3
+
+//Bulletpoints returns the non empty ones, so their position isn't kept
+func (recipe *Recipe) Bulletpoints() []string {
+	bulletpoints := []string{}
+	for _, b := range []string{recipe.Bulletpoint1, recipe.Bulletpoint2, recipe.Bulletpoint3} {
+		if b != "" {
+			bulletpoints = append(bulletpoints, b)
+		}
+	}
+	return bulletpoints
+}
+
+//SetBulletpoints fails with ValidationError of "bulletpoints" field when there are more than MaxBulletpoints
+func (recipe *Recipe) SetBulletpoints(bulletpoints []string) error {
+	if len(bulletpoints) > MaxBulletpoints {
+		return &ValidationError{Violations: []Violation{{
+			Field:   "bulletpoints",
+			Rule:    "max",
+			Message: fmt.Sprintf("has to have at most %d items", MaxBulletpoints),
+		}}}
+	}
+	b := make([]string, MaxBulletpoints)
+	copy(b, bulletpoints)
+	recipe.Bulletpoint1, recipe.Bulletpoint2, recipe.Bulletpoint3 = b[0], b[1], b[2]
+	return nil
+}
+
+//Ingredients splits InYourBox on commas
+func (recipe *Recipe) Ingredients() []string {
+	ingredients := []string{}
+	for _, ingredient := range strings.Split(recipe.InYourBox, ",") {
+		if ingredient = strings.TrimSpace(ingredient); ingredient != "" {
+			ingredients = append(ingredients, ingredient)
+		}
+	}
+	return ingredients
+}
+
+//SetIngredients is reverse of Ingredients
+func (recipe *Recipe) SetIngredients(ingredients []string) {
+	recipe.InYourBox = strings.Join(ingredients, ", ")
 }
 
 type RecipeRate struct {
@@ -80,31 +147,66 @@ type RecipeRate struct {
 }
 
 type RecipesModel struct {
-	mx      sync.Mutex
-	recipes map[int]*Recipe
+	mx                    sync.Mutex
+	recipes               map[int]*Recipe
+	slugs                 map[string]int
+	slugRedirects         map[string]int
+	goustoReferences      map[int]map[int]struct{}
+	uniqueGoustoReference bool
+	columnMapping         ColumnMapping
+	events                *eventLog
+	outbox                *outbox
+	observeLockWait       func(wait time.Duration)
+	rowsImported          int
+	rowsSkipped           int
 }
 
-func NewRecipesModel() *RecipesModel {
-	return &RecipesModel{
-		recipes: make(map[int]*Recipe),
+//Option allows to tweak RecipesModel rules on creation
+type Option func(*RecipesModel)
+
+func NewRecipesModel(opts ...Option) *RecipesModel {
+	r := &RecipesModel{
+		recipes:          make(map[int]*Recipe),
+		slugs:            make(map[string]int),
+		slugRedirects:    make(map[string]int),
+		goustoReferences: make(map[int]map[int]struct{}),
+		columnMapping:    DefaultColumnMapping(),
+		events:           newEventLog(DefaultEventsCapacity),
+		outbox:           newOutbox(),
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
+//LoadFromCSV is all or nothing import, see Import for details about what went wrong
 func (r *RecipesModel) LoadFromCSV(csv io.Reader) error {
-	loadedRecipes := []*Recipe{}
-	if err := gocsv.Unmarshal(csv, &loadedRecipes); err != nil {
-		return errors.Wrapf(err, "failed to unmarshal csv")
-	}
-	r.mx.Lock()
-	for _, recipe := range loadedRecipes {
+	_, err := r.Import(csv, FailFast)
+	return err
+}
+
+//store puts loaded recipes in place overwriting existing ones with the same id.
+//Must be called with lock held.
+func (r *RecipesModel) store(recipes []*Recipe) {
+	events := make([]Event, 0, len(recipes))
+	for _, recipe := range recipes {
+		if old, ok := r.recipes[recipe.Id]; ok {
+			r.reindexSlug(recipe.Id, old, recipe)
+			r.unindexGoustoReference(recipe.Id, old.GoustoReference)
+			events = append(events, newEvent(RecipeUpdated, recipe.Id, recipe))
+		} else {
+			r.indexSlug(recipe.Id, recipe)
+			events = append(events, newEvent(RecipeCreated, recipe.Id, recipe))
+		}
+		r.indexGoustoReference(recipe.Id, recipe.GoustoReference)
 		r.recipes[recipe.Id] = recipe
 	}
-	r.mx.Unlock()
-	return nil
+	r.publish(events...)
 }
 
 func (r *RecipesModel) FetchOneByID(recipeID int) (*Recipe, error) {
-	r.mx.Lock()
+	r.lock()
 	defer r.mx.Unlock()
 	if val, ok := r.recipes[recipeID]; ok {
 		return val, nil
@@ -112,6 +214,36 @@ func (r *RecipesModel) FetchOneByID(recipeID int) (*Recipe, error) {
 	return nil, NotFoundError
 }
 
+//FetchManyByIDs keeps order of given ids, both in found and missing ones
+func (r *RecipesModel) FetchManyByIDs(recipeIDs []int) (found []*Recipe, missing []int) {
+	found = []*Recipe{}
+	missing = []int{}
+	r.lock()
+	defer r.mx.Unlock()
+	for _, id := range recipeIDs {
+		if val, ok := r.recipes[id]; ok {
+			found = append(found, val)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return found, missing
+}
+
+//FetchOneBySlug resolves both current and old slugs, so caller should compare returned recipe slug with the asked one
+//to find out if recipe was renamed in the meantime
+func (r *RecipesModel) FetchOneBySlug(slug string) (*Recipe, error) {
+	r.lock()
+	defer r.mx.Unlock()
+	if id, ok := r.slugs[slug]; ok {
+		return r.recipes[id], nil
+	}
+	if id, ok := r.slugRedirects[slug]; ok {
+		return r.recipes[id], nil
+	}
+	return nil, NotFoundError
+}
+
 //FetchRecipes is not ideal but I don't want to be bothered as normal case scenario for me is to use elastic search for that
 //I don't know anyone who likes CSV
 //Worth to notice is that map in go doesn't guarantee order! So an edge case scenario is that next call with different
@@ -120,7 +252,7 @@ func (r *RecipesModel) FetchRecipes(limiter *Limiter) []*Recipe {
 	v := make([]*Recipe, 0, len(r.recipes))
 	var keys []int
 
-	r.mx.Lock()
+	r.lock()
 	for k := range r.recipes {
 		keys = append(keys, k)
 	}
@@ -134,6 +266,9 @@ func (r *RecipesModel) FetchRecipes(limiter *Limiter) []*Recipe {
 	}
 
 	firstElementIndex := (limiter.Page - 1) * limiter.Limit
+	if firstElementIndex > len(v) {
+		firstElementIndex = len(v)
+	}
 	lastElementIndex := limiter.Page * limiter.Limit
 	if lastElementIndex > len(v) {
 		lastElementIndex = len(v)
@@ -143,23 +278,71 @@ func (r *RecipesModel) FetchRecipes(limiter *Limiter) []*Recipe {
 
 //Right, normal database you would sort out id for me, but I won't be bothered. ID is required.
 func (r *RecipesModel) CreateRecipe(recipe *Recipe) error {
-	r.mx.Lock()
+	r.lock()
 	defer r.mx.Unlock()
-	if _, ok := r.recipes[recipe.Id]; ok {
-		return DuplicateError
+	if err := r.createRecipe(recipe); err != nil {
+		return err
 	}
-	r.recipes[recipe.Id] = recipe
+	r.publish(newEvent(RecipeCreated, recipe.Id, recipe))
 	return nil
 }
 
 //UpdateRecipe isn't what I would leave but I really don't want to waste more time (id collision possible)
 func (r *RecipesModel) UpdateRecipe(recipeID int, recipe *Recipe) error {
-	r.mx.Lock()
+	r.lock()
 	defer r.mx.Unlock()
-	if _, ok := r.recipes[recipeID]; ok {
+	if err := r.updateRecipe(recipeID, recipe); err != nil {
+		return err
+	}
+	r.publish(newEvent(RecipeUpdated, recipeID, recipe))
+	return nil
+}
+
+//UpsertRecipe creates recipe or replaces existing one with the same id
+func (r *RecipesModel) UpsertRecipe(recipe *Recipe) error {
+	r.lock()
+	defer r.mx.Unlock()
+	eventType := RecipeCreated
+	var err error
+	if _, ok := r.recipes[recipe.Id]; ok {
+		eventType = RecipeUpdated
+		err = r.updateRecipe(recipe.Id, recipe)
+	} else {
+		err = r.createRecipe(recipe)
+	}
+	if err != nil {
+		return err
+	}
+	r.publish(newEvent(eventType, recipe.Id, recipe))
+	return nil
+}
+
+//Must be called with lock held.
+func (r *RecipesModel) createRecipe(recipe *Recipe) error {
+	if _, ok := r.recipes[recipe.Id]; ok {
+		return DuplicateError
+	}
+	if r.goustoReferenceTaken(recipe.GoustoReference, recipe.Id) {
+		return GoustoReferenceConflictError
+	}
+	r.indexSlug(recipe.Id, recipe)
+	r.indexGoustoReference(recipe.Id, recipe.GoustoReference)
+	r.recipes[recipe.Id] = recipe
+	return nil
+}
+
+//Must be called with lock held.
+func (r *RecipesModel) updateRecipe(recipeID int, recipe *Recipe) error {
+	if old, ok := r.recipes[recipeID]; ok {
+		if r.goustoReferenceTaken(recipe.GoustoReference, recipeID) {
+			return GoustoReferenceConflictError
+		}
 		if recipeID != recipe.Id {
 			delete(r.recipes, recipeID)
 		}
+		r.reindexSlug(recipeID, old, recipe)
+		r.unindexGoustoReference(recipeID, old.GoustoReference)
+		r.indexGoustoReference(recipeID, recipe.GoustoReference)
 		r.recipes[recipeID] = recipe
 		return nil
 	}
@@ -167,16 +350,27 @@ func (r *RecipesModel) UpdateRecipe(recipeID int, recipe *Recipe) error {
 }
 
 func (r *RecipesModel) RateRecipe(recipeID int, rate *RecipeRate) error {
-	r.mx.Lock()
+	r.lock()
 	defer r.mx.Unlock()
 	if recipe, ok := r.recipes[recipeID]; ok {
 		recipe.rates = append(recipe.rates, rate)
 		recipe.AverageRate = r.calculateAverageRate(recipe)
+		r.publish(newEvent(RecipeRated, recipeID, recipe))
 		return nil
 	}
 	return NotFoundError
 }
 
+//FetchRates returns rates in the order they were given
+func (r *RecipesModel) FetchRates(recipeID int) ([]*RecipeRate, error) {
+	r.lock()
+	defer r.mx.Unlock()
+	if recipe, ok := r.recipes[recipeID]; ok {
+		return append([]*RecipeRate{}, recipe.rates...), nil
+	}
+	return nil, NotFoundError
+}
+
 func (r *RecipesModel) calculateAverageRate(recipe *Recipe) float32 {
 	var sum int
 	for _, rate := range recipe.rates {
@@ -1,11 +1,15 @@
 package model_test
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/gobonoid/svc-recipes/model"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -33,6 +37,7 @@ func Test_LoadFromCSV_ValidCSV(t *testing.T) {
 
 	expectedCreatedAt, _ := time.Parse("02/01/2006 15:04:05", "30/06/2015 17:58:00")
 	assert.True(testRecipe.CreatedAt.Equal(expectedCreatedAt), "CreatedAt is not what was expected")
+	assert.True(testRecipe.UpdatedAt.Equal(expectedCreatedAt), "UpdatedAt is not what was expected")
 
 	assert.Equal("vegetarian", testRecipe.BoxType)
 	assert.Equal("test_title", testRecipe.Title)
@@ -81,6 +86,9 @@ func TestRecipesModel_FetchRecipes(t *testing.T) {
 
 	recipes = recipesModel.FetchRecipes(&model.Limiter{})
 	assert.Equal(t, 10, len(recipes))
+
+	recipes = recipesModel.FetchRecipes(&model.Limiter{Limit: 5, Page: 10})
+	assert.Empty(t, recipes)
 }
 
 func TestRecipesModel_CreateRecipe(t *testing.T) {
@@ -113,3 +121,503 @@ func TestRecipesModel_UpdateRecipe(t *testing.T) {
 	assert.Equal(t, model.NotFoundError, recipesModel.UpdateRecipe(2, &model.Recipe{Id: 1, CaloriesKCal: 5}))
 
 }
+
+func TestRecipesModel_FetchOneBySlug(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Katsu Curry"}))
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 2, Title: "Pork  Katsu curry!"}))
+
+	recipe, err := recipesModel.FetchOneBySlug("pork-katsu-curry")
+	require.NoError(t, err)
+	assert.Equal(t, 1, recipe.Id)
+	recipe, err = recipesModel.FetchOneBySlug("pork-katsu-curry-2")
+	require.NoError(t, err)
+	assert.Equal(t, 2, recipe.Id)
+
+	_, err = recipesModel.FetchOneBySlug("nope")
+	assert.Equal(t, model.NotFoundError, err)
+}
+
+func TestRecipesModel_FetchOneBySlug_Renamed(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Chilli"}))
+	require.NoError(t, recipesModel.UpdateRecipe(1, &model.Recipe{Id: 1, Title: "Pork Chilli", CaloriesKCal: 5}))
+	recipe, err := recipesModel.FetchOneBySlug("pork-chilli")
+	require.NoError(t, err)
+	assert.Equal(t, "pork-chilli", recipe.Slug)
+
+	require.NoError(t, recipesModel.UpdateRecipe(1, &model.Recipe{Id: 1, Title: "Spicy Pork Chilli"}))
+	recipe, err = recipesModel.FetchOneBySlug("pork-chilli")
+	require.NoError(t, err)
+	assert.Equal(t, "spicy-pork-chilli", recipe.Slug)
+
+	//old slug can be reused by new recipe
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 2, Title: "Pork Chilli"}))
+	recipe, err = recipesModel.FetchOneBySlug("pork-chilli")
+	require.NoError(t, err)
+	assert.Equal(t, 2, recipe.Id)
+}
+
+func TestRecipesModel_FetchByGoustoReference(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.LoadFromCSV(strings.NewReader(TestCSVString)))
+	recipes := recipesModel.FetchByGoustoReference(59)
+	require.Equal(t, 2, len(recipes))
+	assert.Equal(t, 1, recipes[0].Id)
+	assert.Equal(t, 7, recipes[1].Id)
+	assert.Empty(t, recipesModel.FetchByGoustoReference(12345))
+
+	conflicts := recipesModel.GoustoReferenceConflicts()
+	assert.Equal(t, map[int][]int{59: {1, 7}, 56: {4, 10}}, conflicts)
+}
+
+func TestRecipesModel_UniqueGoustoReference(t *testing.T) {
+	recipesModel := model.NewRecipesModel(model.UniqueGoustoReference())
+	err := recipesModel.LoadFromCSV(strings.NewReader(TestCSVString))
+	assert.Equal(t, model.InvalidImportError, errors.Cause(err))
+	assert.Empty(t, recipesModel.FetchRecipes(&model.Limiter{}))
+
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, GoustoReference: 59}))
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 2}))
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 3}))
+	assert.Equal(t, model.GoustoReferenceConflictError, recipesModel.CreateRecipe(&model.Recipe{Id: 4, GoustoReference: 59}))
+	assert.Equal(t, model.GoustoReferenceConflictError, recipesModel.UpdateRecipe(2, &model.Recipe{Id: 2, GoustoReference: 59}))
+	require.NoError(t, recipesModel.UpdateRecipe(1, &model.Recipe{Id: 1, GoustoReference: 60}))
+	require.NoError(t, recipesModel.UpdateRecipe(2, &model.Recipe{Id: 2, GoustoReference: 59}))
+}
+
+const TestInvalidCSVString = `id,created_at,title,calories_kcal
+1,30/06/2015 17:58:00,ok,401
+2,yesterday,broken date,12
+3,30/06/2015 17:58:00,broken calories,a lot
+4,30/06/2015 17:58:00,too many,1,2
+5,30/06/2015 17:58:00,ok too,100`
+
+func TestRecipesModel_Import_FailFast(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	report, err := recipesModel.Import(strings.NewReader(TestInvalidCSVString), model.FailFast)
+	assert.Equal(t, model.InvalidImportError, errors.Cause(err))
+	require.Equal(t, 1, len(report.Errors))
+	assert.Equal(t, model.RowError{Line: 3, Column: "created_at", Reason: report.Errors[0].Reason}, report.Errors[0])
+	assert.Empty(t, recipesModel.FetchRecipes(&model.Limiter{}))
+}
+
+func TestRecipesModel_Import_SkipInvalid(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	report, err := recipesModel.Import(strings.NewReader(TestInvalidCSVString), model.SkipInvalid)
+	require.NoError(t, err)
+	assert.Equal(t, 5, report.RowsRead)
+	assert.Equal(t, 2, report.RowsImported)
+	assert.Equal(t, 3, report.RowsSkipped)
+	require.Equal(t, 3, len(report.Errors))
+	assert.Equal(t, 3, report.Errors[0].Line)
+	assert.Equal(t, "created_at", report.Errors[0].Column)
+	assert.Equal(t, 4, report.Errors[1].Line)
+	assert.Equal(t, "calories_kcal", report.Errors[1].Column)
+	assert.Equal(t, `"a lot" is not a valid integer`, report.Errors[1].Reason)
+	assert.Equal(t, 5, report.Errors[2].Line)
+	assert.Equal(t, "", report.Errors[2].Column)
+	assert.Equal(t, 2, len(recipesModel.FetchRecipes(&model.Limiter{})))
+}
+
+func TestRecipesModel_Import_DuplicateIDs(t *testing.T) {
+	const csv = `id,title
+1,Pork Chilli
+2,Fish Pie
+1,Beef Chilli`
+	recipesModel := model.NewRecipesModel()
+	report, err := recipesModel.Import(strings.NewReader(csv), model.SkipInvalid)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.RowsImported)
+	assert.Equal(t, []model.RowError{{Line: 4, Column: "id", Reason: "id 1 already used on line 2"}}, report.Errors)
+	recipe, err := recipesModel.FetchOneByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, "Pork Chilli", recipe.Title)
+
+	recipesModel = model.NewRecipesModel()
+	_, err = recipesModel.Import(strings.NewReader(csv), model.FailFast)
+	assert.Equal(t, model.InvalidImportError, errors.Cause(err))
+	assert.Empty(t, recipesModel.FetchRecipes(&model.Limiter{}))
+}
+
+func TestRecipesModel_Import_DryRun(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	report, err := recipesModel.Import(strings.NewReader(TestInvalidCSVString), model.DryRun)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.RowsValid)
+	assert.Equal(t, 0, report.RowsImported)
+	assert.Equal(t, 3, len(report.Errors))
+	assert.Empty(t, recipesModel.FetchRecipes(&model.Limiter{}))
+}
+
+func TestRecipesModel_Stats(t *testing.T) {
+	var waits int
+	recipesModel := model.NewRecipesModel(model.ObserveLockWait(func(wait time.Duration) {
+		waits++
+	}))
+	_, err := recipesModel.Import(strings.NewReader(TestInvalidCSVString), model.SkipInvalid)
+	require.NoError(t, err)
+	_, err = recipesModel.Import(strings.NewReader(TestInvalidCSVString), model.DryRun)
+	require.NoError(t, err)
+	recipes := recipesModel.FetchRecipes(&model.Limiter{})
+	require.NoError(t, recipesModel.RateRecipe(recipes[0].Id, &model.RecipeRate{Rate: 5}))
+	require.NoError(t, recipesModel.RateRecipe(recipes[1].Id, &model.RecipeRate{Rate: 3}))
+
+	assert.Equal(t, model.Stats{Recipes: 2, Rates: 2, RowsImported: 2, RowsSkipped: 3, EventsPending: 4}, recipesModel.Stats())
+	assert.Equal(t, 6, waits)
+}
+
+func TestRecipesModel_LoadFromCSV_ShippedCSV(t *testing.T) {
+	csv, err := os.Open("../recipe-data.csv")
+	require.NoError(t, err)
+	defer csv.Close()
+	recipesModel := model.NewRecipesModel()
+	report, err := recipesModel.Import(csv, model.FailFast)
+	require.NoError(t, err)
+	assert.Equal(t, 10, report.RowsImported)
+	assert.Empty(t, report.Errors)
+
+	recipe, err := recipesModel.FetchOneByID(6)
+	require.NoError(t, err)
+	expectedUpdatedAt, _ := time.Parse("02/01/2006 15:04:05", "01/07/2015 17:58:00")
+	assert.True(t, recipe.UpdatedAt.Equal(expectedUpdatedAt), "UpdatedAt is not what was expected")
+}
+
+func TestRecipesModel_Import_ColumnMapping(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	report, err := recipesModel.Import(strings.NewReader("id,title,uploaded_at,colour,title\n1,a,30/06/2015 17:58:00,red,b"), model.DryRun)
+	assert.Equal(t, model.InvalidImportError, errors.Cause(err))
+	assert.Equal(t, []model.RowError{
+		{Line: 1, Column: "colour", Reason: "unknown column"},
+		{Line: 1, Column: "title", Reason: "duplicates column title"},
+	}, report.Errors)
+
+	report, err = recipesModel.Import(strings.NewReader("title,calories\na,1"), model.DryRun)
+	assert.Equal(t, model.InvalidImportError, errors.Cause(err))
+	assert.Equal(t, []model.RowError{
+		{Line: 1, Column: "calories", Reason: "unknown column"},
+		{Line: 1, Column: "id", Reason: "required column missing"},
+	}, report.Errors)
+
+	recipesModel = model.NewRecipesModel(model.WithColumnMapping(model.ColumnMapping{
+		Aliases:        map[string]string{"calories": "calories_kcal"},
+		Required:       []string{"id"},
+		UnknownColumns: model.IgnoreUnknownColumns,
+	}))
+	require.NoError(t, recipesModel.LoadFromCSV(strings.NewReader("id,title,calories,colour\n1,Pork Chilli,401,red")))
+	recipe, err := recipesModel.FetchOneByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, 401, recipe.CaloriesKCal)
+}
+
+func TestDateTime_Unmarshal(t *testing.T) {
+	expected := time.Date(2015, 6, 30, 17, 58, 0, 0, time.UTC)
+	for _, input := range []string{`"30/06/2015 17:58:00"`, `"2015-06-30T17:58:00Z"`, `"2015-06-30T19:58:00+02:00"`} {
+		var date model.DateTime
+		require.NoError(t, json.Unmarshal([]byte(input), &date), input)
+		assert.True(t, expected.Equal(date.Time), input)
+	}
+	for _, input := range []string{`null`, `""`} {
+		date := model.DateTime{Time: expected}
+		require.NoError(t, json.Unmarshal([]byte(input), &date), input)
+		assert.True(t, date.IsZero(), input)
+	}
+	var date model.DateTime
+	assert.Error(t, json.Unmarshal([]byte(`"yesterday"`), &date))
+	assert.Error(t, json.Unmarshal([]byte(`12`), &date))
+}
+
+func TestDateTime_Marshal(t *testing.T) {
+	date := model.DateTime{Time: time.Date(2015, 6, 30, 19, 58, 0, 0, time.FixedZone("CEST", 2*60*60))}
+	out, err := json.Marshal(date)
+	require.NoError(t, err)
+	assert.Equal(t, `"2015-06-30T17:58:00Z"`, string(out))
+	csv, err := date.MarshalCSV()
+	require.NoError(t, err)
+	assert.Equal(t, "30/06/2015 17:58:00", csv)
+
+	out, err = json.Marshal(model.DateTime{})
+	require.NoError(t, err)
+	assert.Equal(t, `null`, string(out))
+	csv, err = model.DateTime{}.MarshalCSV()
+	require.NoError(t, err)
+	assert.Equal(t, "", csv)
+}
+
+func TestRecipe_JSONRoundTrip(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.LoadFromCSV(strings.NewReader(TestCSVString)))
+	recipe, err := recipesModel.FetchOneByID(1)
+	require.NoError(t, err)
+	out, err := json.Marshal(recipe)
+	require.NoError(t, err)
+	var decoded model.Recipe
+	require.NoError(t, json.Unmarshal(out, &decoded))
+	assert.True(t, recipe.CreatedAt.Equal(decoded.CreatedAt.Time))
+	assert.True(t, recipe.UpdatedAt.Equal(decoded.UpdatedAt.Time))
+}
+
+func TestRecipesModel_FetchManyByIDs(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.LoadFromCSV(strings.NewReader(TestCSVString)))
+	found, missing := recipesModel.FetchManyByIDs([]int{3, 42, 1, 7})
+	require.Equal(t, 3, len(found))
+	assert.Equal(t, 3, found[0].Id)
+	assert.Equal(t, 1, found[1].Id)
+	assert.Equal(t, 7, found[2].Id)
+	assert.Equal(t, []int{42}, missing)
+}
+
+func TestRecipesModel_ApplyBatch(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Chilli", GoustoReference: 59}))
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 2, Title: "Fish Pie"}))
+
+	results, err := recipesModel.ApplyBatch([]model.BatchOperation{
+		{Op: model.BatchCreate, ID: 3, Recipe: &model.Recipe{Title: "Lamb Curry"}},
+		{Op: model.BatchUpdate, ID: 3, Recipe: &model.Recipe{Title: "Lamb Korma"}},
+		{Op: model.BatchDelete, ID: 1},
+	})
+	require.NoError(t, err)
+	for _, result := range results {
+		assert.Equal(t, model.BatchApplied, result.Status)
+	}
+	recipe, err := recipesModel.FetchOneBySlug("lamb-curry")
+	require.NoError(t, err)
+	assert.Equal(t, "lamb-korma", recipe.Slug)
+	_, err = recipesModel.FetchOneByID(1)
+	assert.Equal(t, model.NotFoundError, err)
+	_, err = recipesModel.FetchOneBySlug("pork-chilli")
+	assert.Equal(t, model.NotFoundError, err)
+	assert.Empty(t, recipesModel.FetchByGoustoReference(59))
+}
+
+func TestRecipesModel_ApplyBatch_RollsBack(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Chilli", GoustoReference: 59}))
+
+	results, err := recipesModel.ApplyBatch([]model.BatchOperation{
+		{Op: model.BatchUpdate, ID: 1, Recipe: &model.Recipe{Title: "Beef Chilli"}},
+		{Op: model.BatchCreate, ID: 2, Recipe: &model.Recipe{Title: "Fish Pie"}},
+		{Op: model.BatchDelete, ID: 42},
+		{Op: model.BatchCreate, ID: 1, Recipe: &model.Recipe{Title: "Duplicate"}},
+	})
+	assert.Equal(t, model.BatchAbortedError, err)
+	require.Equal(t, 4, len(results))
+	assert.Equal(t, model.BatchRolledBack, results[0].Status)
+	assert.Equal(t, model.BatchRolledBack, results[1].Status)
+	assert.Equal(t, model.BatchFailed, results[2].Status)
+	assert.Equal(t, model.NotFoundError.Error(), results[2].Error)
+	assert.Equal(t, model.BatchFailed, results[3].Status)
+	assert.Equal(t, model.DuplicateError.Error(), results[3].Error)
+
+	recipe, err := recipesModel.FetchOneByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, "Pork Chilli", recipe.Title)
+	_, err = recipesModel.FetchOneBySlug("beef-chilli")
+	assert.Equal(t, model.NotFoundError, err)
+	_, err = recipesModel.FetchOneByID(2)
+	assert.Equal(t, model.NotFoundError, err)
+	assert.Equal(t, 1, len(recipesModel.FetchByGoustoReference(59)))
+}
+
+func TestRecipe_Validate(t *testing.T) {
+	assert.NoError(t, (&model.Recipe{Title: "Pork Chilli", BoxType: "gourmet", PreparationTimeMinutes: 35}).Validate())
+
+	err := (&model.Recipe{
+		Title:                  " ",
+		BoxType:                "family",
+		CaloriesKCal:           -1,
+		PreparationTimeMinutes: 601,
+		RecipeCuisine:          "martian",
+		ShortTitle:             strings.Repeat("ą", 256),
+	}).Validate()
+	require.IsType(t, &model.ValidationError{}, err)
+	assert.Equal(t, []model.Violation{
+		{Field: "box_type", Rule: "oneof", Message: "has to be one of: gourmet, vegetarian"},
+		{Field: "title", Rule: "required", Message: "is required"},
+		{Field: "short_title", Rule: "max", Message: "has to be at most 255 characters"},
+		{Field: "calories_k_cal", Rule: "min", Message: "has to be at least 0"},
+		{Field: "preparation_time_minutes", Rule: "max", Message: "has to be at most 600"},
+		{Field: "recipe_cuisine", Rule: "oneof", Message: "has to be one of: asian, british, italian, mediterranean, mexican"},
+	}, err.(*model.ValidationError).Violations)
+}
+
+func TestRecipesModel_Import_Validation(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	report, err := recipesModel.Import(strings.NewReader("id,title,calories_kcal\n1,Pork Chilli,-5\n2,Fish Pie,400"), model.SkipInvalid)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.RowsImported)
+	assert.Equal(t, []model.RowError{{Line: 2, Column: "calories_kcal", Reason: "has to be at least 0"}}, report.Errors)
+}
+
+func TestRecipesModel_SubscribeEvents(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Chilli"}))
+	require.NoError(t, recipesModel.RateRecipe(1, &model.RecipeRate{Rate: 4}))
+	subscription := recipesModel.SubscribeEvents(1)
+	defer subscription.Close()
+	assert.False(t, subscription.Lost)
+	require.Equal(t, 1, len(subscription.Backlog))
+	assert.Equal(t, model.RecipeRated, subscription.Backlog[0].Type)
+	assert.Equal(t, float32(4), subscription.Backlog[0].Recipe.AverageRate)
+
+	require.NoError(t, recipesModel.UpsertRecipe(&model.Recipe{Id: 1, Title: "Beef Chilli"}))
+	//rolled back batch publishes nothing
+	_, err := recipesModel.ApplyBatch([]model.BatchOperation{{Op: model.BatchDelete, ID: 1}, {Op: model.BatchDelete, ID: 42}})
+	require.Equal(t, model.BatchAbortedError, err)
+	_, err = recipesModel.ApplyBatch([]model.BatchOperation{
+		{Op: model.BatchCreate, ID: 2, Recipe: &model.Recipe{Title: "Fish Pie"}},
+		{Op: model.BatchDelete, ID: 1},
+	})
+	require.NoError(t, err)
+
+	var events []string
+	for len(events) < 3 {
+		event := <-subscription.Events
+		events = append(events, fmt.Sprintf("%d %s %d", event.ID, event.Type, event.RecipeID))
+		if event.Type == model.RecipeDeleted {
+			assert.Nil(t, event.Recipe)
+		} else {
+			assert.NotNil(t, event.Recipe)
+		}
+	}
+	assert.Equal(t, []string{"3 updated 1", "4 created 2", "5 deleted 1"}, events)
+}
+
+func TestRecipesModel_SubscribeEvents_Lost(t *testing.T) {
+	recipesModel := model.NewRecipesModel(model.EventsCapacity(2))
+	require.NoError(t, recipesModel.LoadFromCSV(strings.NewReader(TestCSVString)))
+
+	subscription := recipesModel.SubscribeEvents(7)
+	subscription.Close()
+	subscription.Close()
+	assert.True(t, subscription.Lost)
+	require.Equal(t, 2, len(subscription.Backlog))
+	assert.Equal(t, int64(9), subscription.Backlog[0].ID)
+	_, open := <-subscription.Events
+	assert.False(t, open)
+
+	assert.False(t, recipesModel.SubscribeEvents(8).Lost)
+	//ID from before restart
+	assert.True(t, recipesModel.SubscribeEvents(42).Lost)
+
+	//subscriber which doesn't keep up is dropped
+	subscription = recipesModel.SubscribeEvents(10)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, recipesModel.RateRecipe(1, &model.RecipeRate{Rate: 5}))
+	}
+	received := 0
+	for range subscription.Events {
+		received++
+	}
+	assert.True(t, received < 100)
+}
+
+func TestRecipesModel_Subscribe(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Chilli"}))
+
+	handled := make(chan model.Event, 10)
+	failures := 1
+	unsubscribe := recipesModel.Subscribe(model.SubscriberFunc(func(event model.Event) error {
+		//first failure is repeated, later events wait for it
+		if failures > 0 {
+			failures--
+			return errors.New("index is down")
+		}
+		handled <- event
+		return nil
+	}), model.RecipeRated, model.RecipeDeleted)
+	all := make(chan model.Event, 10)
+	defer recipesModel.Subscribe(model.SubscriberFunc(func(event model.Event) error {
+		all <- event
+		return nil
+	}))()
+
+	require.NoError(t, recipesModel.RateRecipe(1, &model.RecipeRate{Rate: 3}))
+	require.NoError(t, recipesModel.UpdateRecipe(1, &model.Recipe{Id: 1, Title: "Beef Chilli"}))
+	_, err := recipesModel.ApplyBatch([]model.BatchOperation{{Op: model.BatchDelete, ID: 1}, {Op: model.BatchDelete, ID: 42}})
+	require.Equal(t, model.BatchAbortedError, err)
+	_, err = recipesModel.ApplyBatch([]model.BatchOperation{{Op: model.BatchDelete, ID: 1}})
+	require.NoError(t, err)
+
+	next := func(events chan model.Event) string {
+		select {
+		case event := <-events:
+			return fmt.Sprintf("%d %s", event.ID, event.Type)
+		case <-time.After(5 * time.Second):
+			return "timeout"
+		}
+	}
+	assert.Equal(t, "2 rated", next(handled))
+	assert.Equal(t, "4 deleted", next(handled))
+	assert.Equal(t, "2 rated", next(all))
+	assert.Equal(t, "3 updated", next(all))
+	assert.Equal(t, "4 deleted", next(all))
+
+	unsubscribe()
+	unsubscribe()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 2, Title: "Fish Pie"}))
+	assert.Equal(t, "5 created", next(all))
+	assert.Empty(t, handled)
+}
+
+func TestRecipesModel_Subscribe_Parked(t *testing.T) {
+	parked := make(chan string, 1)
+	recipesModel := model.NewRecipesModel(model.DeliveryAttempts(2), model.OnParked(func(event model.Event, err error) {
+		parked <- fmt.Sprintf("%d %s: %s", event.ID, event.Type, err)
+	}))
+	handled := make(chan model.Event, 10)
+	defer recipesModel.Subscribe(model.SubscriberFunc(func(event model.Event) error {
+		//subscriber can't handle the first recipe, events after it go on once it's parked
+		if event.RecipeID == 1 {
+			return errors.New("index rejected the recipe")
+		}
+		handled <- event
+		return nil
+	}))()
+
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Chilli"}))
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 2, Title: "Fish Pie"}))
+	select {
+	case event := <-handled:
+		assert.Equal(t, 2, event.RecipeID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("later event waits for the parked one")
+	}
+	assert.Equal(t, "1 created: index rejected the recipe", <-parked)
+	//event 2 is acked right after Handle returns
+	stats := recipesModel.Stats()
+	for i := 0; i < 100 && stats.EventsPending > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		stats = recipesModel.Stats()
+	}
+	assert.Equal(t, 1, stats.EventsParked)
+	assert.Equal(t, 0, stats.EventsPending)
+}
+
+func TestRecipesModel_Subscribe_Retention(t *testing.T) {
+	recipesModel := model.NewRecipesModel(model.OutboxRetention(2))
+	//nobody is subscribed yet, the last two events wait for the first subscriber
+	for id := 1; id <= 3; id++ {
+		require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: id, Title: "Pork Chilli"}))
+	}
+	stats := recipesModel.Stats()
+	assert.Equal(t, 2, stats.EventsPending)
+	assert.Equal(t, 1, stats.EventsEvicted)
+
+	handled := make(chan model.Event, 10)
+	defer recipesModel.Subscribe(model.SubscriberFunc(func(event model.Event) error {
+		handled <- event
+		return nil
+	}))()
+	for _, recipeID := range []int{2, 3} {
+		select {
+		case event := <-handled:
+			assert.Equal(t, recipeID, event.RecipeID)
+		case <-time.After(5 * time.Second):
+			t.Fatal("kept event wasn't delivered")
+		}
+	}
+}
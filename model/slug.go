@@ -0,0 +1,66 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+//Slugify builds url friendly slug out of given title, e.g. "Pork Katsu Curry" -> "pork-katsu-curry"
+func Slugify(title string) string {
+	var b bytes.Buffer
+	dash := false
+	for _, c := range strings.ToLower(title) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			b.WriteRune(c)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimRight(b.String(), "-")
+}
+
+//uniqueSlug returns given slug or its first free numeric variant (slug-2, slug-3...) not used by any other recipe.
+//Must be called with lock held.
+func (r *RecipesModel) uniqueSlug(slug string, recipeID int) string {
+	candidate := slug
+	for i := 2; ; i++ {
+		if id, ok := r.slugs[candidate]; !ok || id == recipeID {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d", slug, i)
+	}
+}
+
+//indexSlug makes sure recipe has unique slug and stores it in the index.
+//Must be called with lock held.
+func (r *RecipesModel) indexSlug(recipeID int, recipe *Recipe) {
+	if recipe.Slug == "" {
+		recipe.Slug = Slugify(recipe.Title)
+	}
+	if recipe.Slug == "" {
+		recipe.Slug = fmt.Sprintf("%d", recipeID)
+	}
+	recipe.Slug = r.uniqueSlug(recipe.Slug, recipeID)
+	r.slugs[recipe.Slug] = recipeID
+	//slug can be taken back from redirects, it's current now
+	delete(r.slugRedirects, recipe.Slug)
+}
+
+//reindexSlug handles slug change on update, old slug is kept as a redirect so existing links keep working.
+//Must be called with lock held.
+func (r *RecipesModel) reindexSlug(recipeID int, old *Recipe, recipe *Recipe) {
+	if recipe.Slug == "" && recipe.Title == old.Title {
+		recipe.Slug = old.Slug
+	}
+	delete(r.slugs, old.Slug)
+	r.indexSlug(recipeID, recipe)
+	if old.Slug != "" && old.Slug != recipe.Slug {
+		r.slugRedirects[old.Slug] = recipeID
+	}
+}
@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+//Stats is a snapshot of model size and of the rows Import went through so far
+type Stats struct {
+	Recipes int
+	Rates   int
+	//RowsImported and RowsSkipped only grow, dry runs and aborted imports don't count
+	RowsImported int
+	RowsSkipped  int
+	//EventsPending are events not handled yet, EventsParked the ones subscribers gave up on and EventsEvicted the
+	//ones dropped beyond outbox retention
+	EventsPending int
+	EventsParked  int
+	EventsEvicted int
+}
+
+type RecipesStatsReporter interface {
+	Stats() Stats
+}
+
+//ObserveLockWait reports how long each call waited for the model lock, observe is called with the lock held
+//so it has to be quick
+func ObserveLockWait(observe func(wait time.Duration)) Option {
+	return func(r *RecipesModel) {
+		r.observeLockWait = observe
+	}
+}
+
+//Stats counts rates of every recipe, it's cheap enough for the catalogue size the model is meant for
+func (r *RecipesModel) Stats() Stats {
+	r.lock()
+	defer r.mx.Unlock()
+	stats := Stats{Recipes: len(r.recipes), RowsImported: r.rowsImported, RowsSkipped: r.rowsSkipped}
+	stats.EventsPending, stats.EventsParked, stats.EventsEvicted = r.outbox.stats()
+	for _, recipe := range r.recipes {
+		stats.Rates += len(recipe.rates)
+	}
+	return stats
+}
+
+//lock takes the model lock, released with r.mx.Unlock as usual
+func (r *RecipesModel) lock() {
+	if r.observeLockWait == nil {
+		r.mx.Lock()
+		return
+	}
+	start := time.Now()
+	r.mx.Lock()
+	r.observeLockWait(time.Since(start))
+}
@@ -0,0 +1,176 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+//ValidationError carries every broken rule of a recipe, not only the first one
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Field+" "+v.Message)
+	}
+	return "Invalid recipe: " + strings.Join(messages, "; ")
+}
+
+//RowErrors reports violations as errors of given import line, columns are JSON names
+func (e *ValidationError) RowErrors(line int) []RowError {
+	rowErrors := make([]RowError, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		rowErrors = append(rowErrors, RowError{Line: line, Column: v.Field, Reason: v.Message})
+	}
+	return rowErrors
+}
+
+//csvRowErrors is RowErrors with CSV column names
+func (e *ValidationError) csvRowErrors(line int) []RowError {
+	rowErrors := e.RowErrors(line)
+	for i := range rowErrors {
+		for _, f := range recipeRules {
+			if f.field == rowErrors[i].Column {
+				rowErrors[i].Column = f.column
+				break
+			}
+		}
+	}
+	return rowErrors
+}
+
+//Violation is a single broken rule, Field is JSON name of the field
+type Violation struct {
+	Field   string `json:"field" xml:"field"`
+	Rule    string `json:"rule" xml:"rule"`
+	Message string `json:"message" xml:"message"`
+}
+
+//rule checks one field value, empty message means it's fine
+type rule struct {
+	name  string
+	check func(v reflect.Value) string
+}
+
+type fieldRules struct {
+	index  int
+	field  string
+	column string
+	rules  []rule
+}
+
+var (
+	recipeRulesOnce sync.Once
+	recipeRules     []fieldRules
+)
+
+//Validate checks Recipe against rules in its validate tags, error is *ValidationError.
+//Rules are: required, min=N and max=N (value for numbers, length for strings) and oneof=a b c.
+//oneof accepts empty string, so optional enums can be left out.
+func (recipe *Recipe) Validate() error {
+	recipeRulesOnce.Do(func() {
+		recipeRules = parseRules(reflect.TypeOf(Recipe{}))
+	})
+	v := reflect.ValueOf(recipe).Elem()
+	var violations []Violation
+	for _, f := range recipeRules {
+		for _, r := range f.rules {
+			if message := r.check(v.Field(f.index)); message != "" {
+				violations = append(violations, Violation{Field: f.field, Rule: r.name, Message: message})
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+//parseRules panics on broken tag as it's a programming error
+func parseRules(t reflect.Type) []fieldRules {
+	var fields []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		f := fieldRules{
+			index:  i,
+			field:  strings.Split(t.Field(i).Tag.Get("json"), ",")[0],
+			column: t.Field(i).Tag.Get("csv"),
+		}
+		for _, definition := range strings.Split(tag, ",") {
+			r, err := parseRule(definition, t.Field(i).Type.Kind())
+			if err != nil {
+				panic(fmt.Sprintf("validate tag of %s: %s", t.Field(i).Name, err))
+			}
+			f.rules = append(f.rules, r)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func parseRule(definition string, kind reflect.Kind) (rule, error) {
+	parts := strings.SplitN(definition, "=", 2)
+	name := parts[0]
+	arg := ""
+	if len(parts) == 2 {
+		arg = parts[1]
+	}
+	switch name {
+	case "required":
+		return rule{name, func(v reflect.Value) string {
+			if kind == reflect.String && strings.TrimSpace(v.String()) == "" || kind == reflect.Int && v.Int() == 0 {
+				return "is required"
+			}
+			return ""
+		}}, nil
+	case "min", "max":
+		limit, err := strconv.Atoi(arg)
+		if err != nil {
+			return rule{}, fmt.Errorf("%s needs a number", name)
+		}
+		return rule{name, func(v reflect.Value) string {
+			var n int
+			unit := ""
+			switch kind {
+			case reflect.String:
+				n = utf8.RuneCountInString(v.String())
+				unit = " characters"
+			case reflect.Int:
+				n = int(v.Int())
+			}
+			if name == "min" && n < limit {
+				return fmt.Sprintf("has to be at least %d%s", limit, unit)
+			}
+			if name == "max" && n > limit {
+				return fmt.Sprintf("has to be at most %d%s", limit, unit)
+			}
+			return ""
+		}}, nil
+	case "oneof":
+		allowed := strings.Fields(arg)
+		if len(allowed) == 0 || kind != reflect.String {
+			return rule{}, fmt.Errorf("oneof needs values and string field")
+		}
+		return rule{name, func(v reflect.Value) string {
+			if v.String() == "" {
+				return ""
+			}
+			for _, a := range allowed {
+				if v.String() == a {
+					return ""
+				}
+			}
+			return "has to be one of: " + strings.Join(allowed, ", ")
+		}}, nil
+	}
+	return rule{}, fmt.Errorf("unknown rule %s", name)
+}
@@ -0,0 +1,131 @@
+package tracing
+
+import (
+	"github.com/gobonoid/svc-recipes/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+var (
+	recipeID        = attribute.Key("recipe.id")
+	recipeSlug      = attribute.Key("recipe.slug")
+	goustoReference = attribute.Key("recipe.gousto_reference")
+	recipesCount    = attribute.Key("recipes.count")
+	limit           = attribute.Key("recipes.limit")
+	page            = attribute.Key("recipes.page")
+	batchOperations = attribute.Key("batch.operations")
+)
+
+//Model gives a span to every call made through it, in the trace of ctx. It's meant to live as long as the request.
+//Event feeds outlive requests, so Subscribe and SubscribeEvents go to the model untraced.
+func Model(ctx context.Context, aggregator model.RecipesAggregator) model.RecipesAggregator {
+	return tracedModel{RecipesAggregator: aggregator, ctx: ctx}
+}
+
+type tracedModel struct {
+	model.RecipesAggregator
+	ctx context.Context
+}
+
+//start names the span after the model method
+func (m tracedModel) start(method string, attributes ...attribute.KeyValue) trace.Span {
+	_, span := Tracer().Start(m.ctx, "RecipesModel."+method, trace.WithAttributes(attributes...))
+	return span
+}
+
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (m tracedModel) FetchOneByID(id int) (*model.Recipe, error) {
+	span := m.start("FetchOneByID", recipeID.Int(id))
+	recipe, err := m.RecipesAggregator.FetchOneByID(id)
+	end(span, err)
+	return recipe, err
+}
+
+func (m tracedModel) FetchOneBySlug(slug string) (*model.Recipe, error) {
+	span := m.start("FetchOneBySlug", recipeSlug.String(slug))
+	recipe, err := m.RecipesAggregator.FetchOneBySlug(slug)
+	end(span, err)
+	return recipe, err
+}
+
+func (m tracedModel) FetchRecipes(limiter *model.Limiter) []*model.Recipe {
+	span := m.start("FetchRecipes", limit.Int(limiter.Limit), page.Int(limiter.Page))
+	recipes := m.RecipesAggregator.FetchRecipes(limiter)
+	span.SetAttributes(recipesCount.Int(len(recipes)))
+	end(span, nil)
+	return recipes
+}
+
+func (m tracedModel) FetchByGoustoReference(reference int) []*model.Recipe {
+	span := m.start("FetchByGoustoReference", goustoReference.Int(reference))
+	recipes := m.RecipesAggregator.FetchByGoustoReference(reference)
+	span.SetAttributes(recipesCount.Int(len(recipes)))
+	end(span, nil)
+	return recipes
+}
+
+func (m tracedModel) FetchManyByIDs(recipeIDs []int) ([]*model.Recipe, []int) {
+	span := m.start("FetchManyByIDs", recipeID.IntSlice(recipeIDs))
+	found, missing := m.RecipesAggregator.FetchManyByIDs(recipeIDs)
+	span.SetAttributes(recipesCount.Int(len(found)))
+	end(span, nil)
+	return found, missing
+}
+
+func (m tracedModel) CreateRecipe(recipe *model.Recipe) error {
+	span := m.start("CreateRecipe", recipeID.Int(recipe.Id))
+	err := m.RecipesAggregator.CreateRecipe(recipe)
+	end(span, err)
+	return err
+}
+
+func (m tracedModel) UpdateRecipe(id int, recipe *model.Recipe) error {
+	span := m.start("UpdateRecipe", recipeID.Int(id))
+	err := m.RecipesAggregator.UpdateRecipe(id, recipe)
+	end(span, err)
+	return err
+}
+
+func (m tracedModel) UpsertRecipe(recipe *model.Recipe) error {
+	span := m.start("UpsertRecipe", recipeID.Int(recipe.Id))
+	err := m.RecipesAggregator.UpsertRecipe(recipe)
+	end(span, err)
+	return err
+}
+
+func (m tracedModel) RateRecipe(id int, rate *model.RecipeRate) error {
+	span := m.start("RateRecipe", recipeID.Int(id))
+	err := m.RecipesAggregator.RateRecipe(id, rate)
+	end(span, err)
+	return err
+}
+
+func (m tracedModel) FetchRates(id int) ([]*model.RecipeRate, error) {
+	span := m.start("FetchRates", recipeID.Int(id))
+	rates, err := m.RecipesAggregator.FetchRates(id)
+	end(span, err)
+	return rates, err
+}
+
+func (m tracedModel) GoustoReferenceConflicts() map[int][]int {
+	span := m.start("GoustoReferenceConflicts")
+	conflicts := m.RecipesAggregator.GoustoReferenceConflicts()
+	end(span, nil)
+	return conflicts
+}
+
+func (m tracedModel) ApplyBatch(operations []model.BatchOperation) ([]model.BatchResult, error) {
+	span := m.start("ApplyBatch", batchOperations.Int(len(operations)))
+	results, err := m.RecipesAggregator.ApplyBatch(operations)
+	end(span, err)
+	return results, err
+}
@@ -0,0 +1,111 @@
+//Package tracing sets up OpenTelemetry and follows requests into the model. Servers continue traces of callers from
+//W3C traceparent header or metadata, see Propagator, spans of model calls hang off them.
+package tracing
+
+import (
+	"io"
+	"os"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+)
+
+//Exporters Setup knows. OTLP sends spans to a collector over HTTP, stdout and file write them as JSON, one object
+//per span, for local testing.
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+
+	serviceName = "svc-recipes"
+	//instrumentationName names the tracer, spans of every package come from the same one
+	instrumentationName = "github.com/gobonoid/svc-recipes"
+)
+
+//Propagator is W3C Trace Context, it's used regardless of the global one so servers continue traces even without Setup
+var Propagator propagation.TextMapPropagator = propagation.TraceContext{}
+
+//Tracer is the global one, so spans go wherever Setup or a test sent them
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+//Settings tell Setup where spans go
+type Settings struct {
+	Exporter string
+	//File is used by ExporterFile only
+	File string
+	//Endpoint is host:port of OTLP/HTTP collector, Insecure sends spans there over plain HTTP
+	Endpoint string
+	Insecure bool
+}
+
+//Setup installs global tracer provider sending spans to the exporter.
+//Returned shutdown flushes spans still in the batch, with ExporterNone spans are created but dropped.
+func Setup(settings Settings) (shutdown func(ctx context.Context) error, err error) {
+	otel.SetTextMapPropagator(Propagator)
+	if settings.Exporter == ExporterNone {
+		return func(ctx context.Context) error { return nil }, nil
+	}
+	var spanExporter sdktrace.SpanExporter
+	var closer io.Closer
+	switch settings.Exporter {
+	case ExporterOTLP:
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(settings.Endpoint)}
+		if settings.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		//the client connects lazily, an unreachable collector shows up as export errors and not here
+		spanExporter, err = otlptracehttp.New(context.Background(), options...)
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+		var f *os.File
+		if f, err = os.OpenFile(settings.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+			return nil, errors.Wrap(err, "can't open traces file")
+		}
+		closer = f
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	default:
+		return nil, errors.Errorf("unknown exporter %q", settings.Exporter)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "can't create exporter")
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			if closeErr := closer.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+//LogFields are trace and span ids of ctx, none when ctx isn't traced
+func LogFields(ctx context.Context) logrus.Fields {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return logrus.Fields{}
+	}
+	return logrus.Fields{
+		"trace_id": spanContext.TraceID().String(),
+		"span_id":  spanContext.SpanID().String(),
+	}
+}
@@ -0,0 +1,99 @@
+package tracing_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/gobonoid/svc-recipes/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/net/context"
+)
+
+func TestModel(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Chilli"}))
+
+	ctx, request := tracing.Tracer().Start(context.Background(), "GET /recipes/:recipeID")
+	traced := tracing.Model(ctx, recipesModel)
+	_, err := traced.FetchOneByID(1)
+	require.NoError(t, err)
+	_, err = traced.FetchOneByID(42)
+	assert.Equal(t, model.NotFoundError, err)
+	request.End()
+
+	spans := recorder.Ended()
+	require.Equal(t, 3, len(spans))
+	for _, span := range spans[:2] {
+		assert.Equal(t, "RecipesModel.FetchOneByID", span.Name())
+		assert.Equal(t, request.SpanContext().TraceID(), span.SpanContext().TraceID())
+		assert.Equal(t, request.SpanContext().SpanID(), span.Parent().SpanID())
+	}
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+
+	fields := tracing.LogFields(ctx)
+	assert.Equal(t, request.SpanContext().TraceID().String(), fields["trace_id"])
+	assert.Equal(t, request.SpanContext().SpanID().String(), fields["span_id"])
+	assert.Empty(t, tracing.LogFields(context.Background()))
+}
+
+func TestSetup_File(t *testing.T) {
+	dir, err := ioutil.TempDir("", "traces")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "traces.json")
+
+	shutdown, err := tracing.Setup(tracing.Settings{Exporter: tracing.ExporterFile, File: file})
+	require.NoError(t, err)
+	_, span := tracing.Tracer().Start(context.Background(), "GET /recipes")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	traces, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.Contains(t, string(traces), `"Name":"GET /recipes"`)
+	assert.Contains(t, string(traces), span.SpanContext().TraceID().String())
+
+	_, err = tracing.Setup(tracing.Settings{Exporter: "zipkin"})
+	assert.NotNil(t, err)
+}
+
+func TestSetup_OTLP(t *testing.T) {
+	exported := make(chan *http.Request, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exported <- r
+	}))
+	defer collector.Close()
+
+	shutdown, err := tracing.Setup(tracing.Settings{
+		Exporter: tracing.ExporterOTLP,
+		Endpoint: strings.TrimPrefix(collector.URL, "http://"),
+		Insecure: true,
+	})
+	require.NoError(t, err)
+	_, span := tracing.Tracer().Start(context.Background(), "GET /recipes")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	select {
+	case r := <-exported:
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+	default:
+		t.Fatal("collector got no spans")
+	}
+}
@@ -0,0 +1,65 @@
+package webhooks
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//newHTTPClient delivers to public addresses only unless allowPrivate. Webhooks are registered without auth and their
+//outcome shows in dead letters, so reaching into the network the service runs in would make them a probe.
+func newHTTPClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = publicOnly
+	}
+	return &http.Client{
+		Timeout: timeout,
+		//a proxy would be dialled instead of the receiver, so none is used
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+		//redirects could lead anywhere, receivers have to answer at the registered URL
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+//publicOnly runs after the host is resolved, so a hostname pointing inside the network is refused as well
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errors.Wrap(err, "incorrect address")
+	}
+	if ip := net.ParseIP(host); ip == nil || !public(ip) {
+		return errors.Errorf("%s is not a public address", host)
+	}
+	return nil
+}
+
+func public(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+//publicURL refuses URLs which name a private host directly, hostnames are checked again once resolved
+func publicURL(u *url.URL) bool {
+	host := strings.TrimSuffix(u.Hostname(), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return public(ip)
+	}
+	return true
+}
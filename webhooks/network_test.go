@@ -0,0 +1,50 @@
+package webhooks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublicOnly(t *testing.T) {
+	for address, allowed := range map[string]bool{
+		"93.184.216.34:443":        true,
+		"[2606:2800:220:1::]:443":  true,
+		"127.0.0.1:8080":           false,
+		"[::1]:8080":               false,
+		"169.254.169.254:80":       false,
+		"[fe80::1]:80":             false,
+		"10.1.2.3:80":              false,
+		"172.16.0.1:80":            false,
+		"192.168.1.1:80":           false,
+		"[fd00::1]:80":             false,
+		"0.0.0.0:80":               false,
+		"224.0.0.1:80":             false,
+		"[::ffff:127.0.0.1]:8080":  false,
+		"[::ffff:93.184.216.34]:1": true,
+	} {
+		assert.Equal(t, allowed, publicOnly("tcp", address, nil) == nil, address)
+	}
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest", http.StatusFound)
+	}))
+	defer receiver.Close()
+
+	//hostname of the receiver doesn't matter, the resolved address is checked
+	_, err := newHTTPClient(time.Second, false).Post(receiver.URL, "application/json", nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "127.0.0.1 is not a public address")
+	}
+
+	res, err := newHTTPClient(time.Second, true).Post(receiver.URL, "application/json", nil)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusFound, res.StatusCode, "redirect isn't followed")
+}
@@ -0,0 +1,396 @@
+//Package webhooks pushes recipe events from the model to partner URLs. Each delivery is signed with the webhook secret
+//and retried with exponential backoff, deliveries which run out of attempts end up on the dead letter list.
+//Webhooks and dead letters are kept in memory.
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+var NotFoundError = errors.New("Webhook not found")
+var DeadLetterNotFoundError = errors.New("Dead letter not found")
+var InvalidWebhookError = errors.New("Invalid webhook")
+
+const (
+	//SignatureHeader is sha256=<hex HMAC-SHA256 of the body keyed with webhook secret>, see Sign
+	SignatureHeader = "X-Recipes-Signature"
+	EventHeader     = "X-Recipes-Event"
+	DeliveryHeader  = "X-Recipes-Delivery"
+
+	defaultRetries = 5
+	defaultBackoff = time.Second
+	maxBackoff     = 5 * time.Minute
+	defaultTimeout = 10 * time.Second
+
+	//maxDeadLetters drops the oldest ones when receiver is down for long
+	maxDeadLetters = 1000
+	//maxDeliveries in flight, the rest waits
+	maxDeliveries = 16
+)
+
+//eventTypes webhook can subscribe to
+var eventTypes = map[model.EventType]bool{
+	model.RecipeCreated: true,
+	model.RecipeUpdated: true,
+	model.RecipeDeleted: true,
+	model.RecipeRated:   true,
+}
+
+//Webhook is a subscription of URL to recipe events. Secret is write only, it's never returned.
+type Webhook struct {
+	ID        string            `json:"id"`
+	URL       string            `json:"url"`
+	Events    []model.EventType `json:"events"`
+	Secret    string            `json:"secret,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+func (w *Webhook) validate(allowPrivate bool) error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.Wrap(InvalidWebhookError, "url has to be absolute http or https URL")
+	}
+	if !allowPrivate && !publicURL(u) {
+		return errors.Wrap(InvalidWebhookError, "url has to point to a public host")
+	}
+	if len(w.Events) == 0 {
+		return errors.Wrap(InvalidWebhookError, "events can't be empty")
+	}
+	for _, event := range w.Events {
+		if !eventTypes[event] {
+			return errors.Wrapf(InvalidWebhookError, "unknown event %q", event)
+		}
+	}
+	if w.Secret == "" {
+		return errors.Wrap(InvalidWebhookError, "secret is required")
+	}
+	return nil
+}
+
+func (w *Webhook) subscribed(eventType model.EventType) bool {
+	for _, event := range w.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+//snapshot is safe to hand out, without the secret
+func (w *Webhook) snapshot() Webhook {
+	s := *w
+	s.Events = append([]model.EventType{}, w.Events...)
+	s.Secret = ""
+	return s
+}
+
+//DeadLetter is a delivery which failed every attempt, ID is the delivery one
+type DeadLetter struct {
+	ID        string      `json:"id"`
+	WebhookID string      `json:"webhook_id"`
+	Event     model.Event `json:"event"`
+	Attempts  int         `json:"attempts"`
+	LastError string      `json:"last_error"`
+	FailedAt  time.Time   `json:"failed_at"`
+}
+
+type delivery struct {
+	id      string
+	webhook *Webhook
+	event   model.Event
+}
+
+//Dispatcher follows model events and delivers them to webhooks subscribed to them. Deliveries run concurrently,
+//so receivers should order events by their id rather than by arrival.
+type Dispatcher struct {
+	log          *logrus.Logger
+	httpClient   *http.Client
+	timeout      time.Duration
+	allowPrivate bool
+	retries      int
+	backoff      time.Duration
+
+	mx             sync.Mutex
+	webhooks       map[string]*Webhook
+	lastID         int
+	lastDeliveryID int
+	deadLetters    []DeadLetter
+
+	unsubscribe func()
+	slots       chan struct{}
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+type Option func(*Dispatcher)
+
+//WithHTTPClient replaces default client, which has 10s timeout, delivers to public addresses only and doesn't follow
+//redirects. The replacement is trusted to do the same.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.httpClient = httpClient
+	}
+}
+
+//WithTimeout is timeout of single delivery attempt with default client
+func WithTimeout(timeout time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.timeout = timeout
+	}
+}
+
+//AllowPrivateNetworks lets webhooks point to loopback, link-local and private addresses, e.g. receivers in tests
+func AllowPrivateNetworks() Option {
+	return func(d *Dispatcher) {
+		d.allowPrivate = true
+	}
+}
+
+//WithRetries sets how many times a failed delivery is repeated and how long to wait before the first repeat,
+//every next wait is twice as long
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.retries = retries
+		d.backoff = backoff
+	}
+}
+
+//NewDispatcher subscribes to events, only the ones published from now on are delivered
+func NewDispatcher(bus model.RecipesEventBus, log *logrus.Logger, options ...Option) *Dispatcher {
+	d := &Dispatcher{
+		log:      log,
+		timeout:  defaultTimeout,
+		retries:  defaultRetries,
+		backoff:  defaultBackoff,
+		webhooks: make(map[string]*Webhook),
+		slots:    make(chan struct{}, maxDeliveries),
+	}
+	for _, option := range options {
+		option(d)
+	}
+	if d.httpClient == nil {
+		d.httpClient = newHTTPClient(d.timeout, d.allowPrivate)
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	d.unsubscribe = bus.Subscribe(model.SubscriberFunc(d.dispatch))
+	return d
+}
+
+func (d *Dispatcher) Register(webhook Webhook) (Webhook, error) {
+	if err := webhook.validate(d.allowPrivate); err != nil {
+		return Webhook{}, err
+	}
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	d.lastID++
+	webhook.ID = strconv.Itoa(d.lastID)
+	webhook.Events = append([]model.EventType{}, webhook.Events...)
+	webhook.CreatedAt = time.Now().UTC()
+	d.webhooks[webhook.ID] = &webhook
+	return webhook.snapshot(), nil
+}
+
+//List returns webhooks in order they were registered
+func (d *Dispatcher) List() []Webhook {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	webhooks := make([]Webhook, 0, len(d.webhooks))
+	for _, webhook := range d.webhooks {
+		webhooks = append(webhooks, webhook.snapshot())
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		a, _ := strconv.Atoi(webhooks[i].ID)
+		b, _ := strconv.Atoi(webhooks[j].ID)
+		return a < b
+	})
+	return webhooks
+}
+
+func (d *Dispatcher) Get(id string) (Webhook, error) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	if webhook, ok := d.webhooks[id]; ok {
+		return webhook.snapshot(), nil
+	}
+	return Webhook{}, NotFoundError
+}
+
+//Delete stops deliveries, including retries of the pending ones
+func (d *Dispatcher) Delete(id string) error {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	if _, ok := d.webhooks[id]; !ok {
+		return NotFoundError
+	}
+	delete(d.webhooks, id)
+	return nil
+}
+
+//DeadLetters returns failed deliveries, the oldest first
+func (d *Dispatcher) DeadLetters() []DeadLetter {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	return append([]DeadLetter{}, d.deadLetters...)
+}
+
+//Redeliver takes dead letter off the list and tries it again with fresh attempts
+func (d *Dispatcher) Redeliver(id string) error {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	for i, deadLetter := range d.deadLetters {
+		if deadLetter.ID != id {
+			continue
+		}
+		webhook, ok := d.webhooks[deadLetter.WebhookID]
+		if !ok {
+			return errors.Wrap(NotFoundError, "webhook of the dead letter was deleted")
+		}
+		d.deadLetters = append(d.deadLetters[:i], d.deadLetters[i+1:]...)
+		d.start(delivery{id: deadLetter.ID, webhook: webhook, event: deadLetter.Event})
+		return nil
+	}
+	return DeadLetterNotFoundError
+}
+
+//Stop gives up pending deliveries and waits for them to finish
+func (d *Dispatcher) Stop() {
+	d.unsubscribe()
+	//under lock, so no delivery starts after it
+	d.mx.Lock()
+	d.cancel()
+	d.mx.Unlock()
+	d.wg.Wait()
+}
+
+//dispatch only starts deliveries, so it never fails and doesn't hold the bus up
+func (d *Dispatcher) dispatch(event model.Event) error {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	for _, webhook := range d.webhooks {
+		if webhook.subscribed(event.Type) {
+			d.lastDeliveryID++
+			d.start(delivery{id: strconv.Itoa(d.lastDeliveryID), webhook: webhook, event: event})
+		}
+	}
+	return nil
+}
+
+//Must be called with lock held.
+func (d *Dispatcher) start(delivery delivery) {
+	if d.ctx.Err() != nil {
+		return
+	}
+	d.wg.Add(1)
+	go d.deliver(delivery)
+}
+
+func (d *Dispatcher) deliver(delivery delivery) {
+	defer d.wg.Done()
+	body, err := json.Marshal(delivery.event)
+	if err != nil {
+		d.log.Errorf("%+v", errors.Wrapf(err, "failed to marshal event %d", delivery.event.ID))
+		return
+	}
+	backoff := d.backoff
+	attempts := 0
+	for {
+		if !d.registered(delivery.webhook) {
+			return
+		}
+		attempts++
+		if err = d.send(delivery, body); err == nil {
+			return
+		}
+		if attempts > d.retries {
+			break
+		}
+		select {
+		case <-d.ctx.Done():
+		case <-time.After(backoff):
+		}
+		if d.ctx.Err() != nil {
+			break
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	d.deadLetter(DeadLetter{
+		ID:        delivery.id,
+		WebhookID: delivery.webhook.ID,
+		Event:     delivery.event,
+		Attempts:  attempts,
+		LastError: err.Error(),
+		FailedAt:  time.Now().UTC(),
+	})
+}
+
+func (d *Dispatcher) registered(webhook *Webhook) bool {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	return d.webhooks[webhook.ID] == webhook
+}
+
+func (d *Dispatcher) deadLetter(deadLetter DeadLetter) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	if len(d.deadLetters) == maxDeadLetters {
+		d.deadLetters = d.deadLetters[1:]
+	}
+	d.deadLetters = append(d.deadLetters, deadLetter)
+}
+
+//send makes single attempt, any 2xx response is success
+func (d *Dispatcher) send(delivery delivery, body []byte) error {
+	select {
+	case d.slots <- struct{}{}:
+		defer func() { <-d.slots }()
+	case <-d.ctx.Done():
+		return errors.Wrap(d.ctx.Err(), "dispatcher stopped")
+	}
+	req, err := http.NewRequest(http.MethodPost, delivery.webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req = req.WithContext(d.ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(delivery.event.Type))
+	req.Header.Set(DeliveryHeader, delivery.id)
+	req.Header.Set(SignatureHeader, Sign(delivery.webhook.Secret, body))
+
+	res, err := d.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "request failed")
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 4096))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.Errorf("receiver responded with %d", res.StatusCode)
+	}
+	return nil
+}
+
+//Sign is the SignatureHeader value of given body, receivers should compare it with hmac.Equal
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,149 @@
+package webhooks_test
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gobonoid/svc-recipes/model"
+	"github.com/gobonoid/svc-recipes/webhooks"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type delivery struct {
+	id    string
+	event model.Event
+}
+
+//receiver verifies signatures and answers with given statuses in turn, 200 once they run out
+type receiver struct {
+	t          *testing.T
+	mx         sync.Mutex
+	statuses   []int
+	deliveries chan delivery
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*receiver, *httptest.Server) {
+	r := &receiver{t: t, statuses: statuses, deliveries: make(chan delivery, 10)}
+	return r, httptest.NewServer(r)
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	require.NoError(r.t, err)
+	assert.True(r.t, hmac.Equal([]byte(webhooks.Sign("s3cret", body)), []byte(req.Header.Get(webhooks.SignatureHeader))))
+	r.mx.Lock()
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	r.mx.Unlock()
+	w.WriteHeader(status)
+	if status == http.StatusOK {
+		var event model.Event
+		require.NoError(r.t, json.Unmarshal(body, &event))
+		assert.Equal(r.t, string(event.Type), req.Header.Get(webhooks.EventHeader))
+		r.deliveries <- delivery{req.Header.Get(webhooks.DeliveryHeader), event}
+	}
+}
+
+func (r *receiver) next() delivery {
+	select {
+	case d := <-r.deliveries:
+		return d
+	case <-time.After(5 * time.Second):
+		r.t.Fatal("No delivery")
+	}
+	return delivery{}
+}
+
+func TestDispatcher(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	r, ts := newReceiver(t, http.StatusInternalServerError)
+	defer ts.Close()
+	d := webhooks.NewDispatcher(recipesModel, logrus.New(), webhooks.WithRetries(2, time.Millisecond), webhooks.AllowPrivateNetworks())
+	defer d.Stop()
+
+	webhook, err := d.Register(webhooks.Webhook{URL: ts.URL, Events: []model.EventType{model.RecipeRated, model.RecipeCreated}, Secret: "s3cret"})
+	require.NoError(t, err)
+	assert.Empty(t, webhook.Secret)
+	assert.Equal(t, []webhooks.Webhook{webhook}, d.List())
+
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Chilli"}))
+	//first attempt failed, second one went through
+	delivered := r.next()
+	assert.Equal(t, model.RecipeCreated, delivered.event.Type)
+	assert.Equal(t, "1", delivered.id)
+
+	require.NoError(t, recipesModel.UpdateRecipe(1, &model.Recipe{Id: 1, Title: "Beef Chilli"}))
+	require.NoError(t, recipesModel.RateRecipe(1, &model.RecipeRate{Rate: 4}))
+	delivered = r.next()
+	assert.Equal(t, model.RecipeRated, delivered.event.Type)
+	assert.Equal(t, "Beef Chilli", delivered.event.Recipe.Title)
+
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 2, Title: "Fish Pie"}))
+	delivered = r.next()
+	assert.Equal(t, model.RecipeCreated, delivered.event.Type)
+	assert.Equal(t, 2, delivered.event.RecipeID)
+	assert.Empty(t, d.DeadLetters())
+
+	require.NoError(t, d.Delete(webhook.ID))
+	assert.Equal(t, webhooks.NotFoundError, d.Delete(webhook.ID))
+	_, err = d.Get(webhook.ID)
+	assert.Equal(t, webhooks.NotFoundError, err)
+}
+
+func TestDispatcher_DeadLetters(t *testing.T) {
+	recipesModel := model.NewRecipesModel()
+	require.NoError(t, recipesModel.CreateRecipe(&model.Recipe{Id: 1, Title: "Pork Chilli"}))
+	r, ts := newReceiver(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusBadGateway)
+	defer ts.Close()
+	d := webhooks.NewDispatcher(recipesModel, logrus.New(), webhooks.WithRetries(2, time.Millisecond), webhooks.AllowPrivateNetworks())
+	defer d.Stop()
+	_, err := d.Register(webhooks.Webhook{URL: ts.URL, Events: []model.EventType{model.RecipeRated}, Secret: "s3cret"})
+	require.NoError(t, err)
+
+	require.NoError(t, recipesModel.RateRecipe(1, &model.RecipeRate{Rate: 4}))
+	var deadLetters []webhooks.DeadLetter
+	for i := 0; i < 500 && len(deadLetters) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		deadLetters = d.DeadLetters()
+	}
+	require.Equal(t, 1, len(deadLetters))
+	assert.Equal(t, 3, deadLetters[0].Attempts)
+	assert.Equal(t, "receiver responded with 502", deadLetters[0].LastError)
+	assert.Equal(t, model.RecipeRated, deadLetters[0].Event.Type)
+
+	require.NoError(t, d.Redeliver(deadLetters[0].ID))
+	assert.Equal(t, deadLetters[0].ID, r.next().id)
+	assert.Empty(t, d.DeadLetters())
+	assert.Equal(t, webhooks.DeadLetterNotFoundError, d.Redeliver(deadLetters[0].ID))
+}
+
+func TestDispatcher_Register_Validation(t *testing.T) {
+	d := webhooks.NewDispatcher(model.NewRecipesModel(), logrus.New())
+	defer d.Stop()
+	for _, webhook := range []webhooks.Webhook{
+		{URL: "/relative", Events: []model.EventType{model.RecipeRated}, Secret: "s"},
+		{URL: "ftp://partner.com", Events: []model.EventType{model.RecipeRated}, Secret: "s"},
+		{URL: "https://partner.com", Secret: "s"},
+		{URL: "https://partner.com", Events: []model.EventType{"eaten"}, Secret: "s"},
+		{URL: "https://partner.com", Events: []model.EventType{model.RecipeRated}},
+		{URL: "http://169.254.169.254/latest", Events: []model.EventType{model.RecipeRated}, Secret: "s"},
+		{URL: "http://localhost:8080", Events: []model.EventType{model.RecipeRated}, Secret: "s"},
+		{URL: "http://[::1]:8080", Events: []model.EventType{model.RecipeRated}, Secret: "s"},
+		{URL: "http://10.0.0.1", Events: []model.EventType{model.RecipeRated}, Secret: "s"},
+	} {
+		_, err := d.Register(webhook)
+		assert.Equal(t, webhooks.InvalidWebhookError, errors.Cause(err), "%v", webhook)
+	}
+	assert.Empty(t, d.List())
+}